@@ -0,0 +1,318 @@
+package componentizer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+//ArchiveScmHandler Represents the scm connector allowing to fetch components
+// distributed as ".tar.gz", ".tgz" or ".zip" archives.
+//
+//If all the archive content is located into a single top-level folder then
+// this folder is stripped during the extraction.
+//
+//It implements "github.com/ekara-platform/engine/component/scm.scmHandler
+type ArchiveScmHandler struct {
+	Logger Logger
+}
+
+//archiveSourceSuffix is the suffix of the file recording, next to an extracted archive,
+// the location of the archive; it is kept out of the component content
+const archiveSourceSuffix = ".source"
+
+//archiveWalkFunc is called for each entry of an archive, the reader is nil for directories
+type archiveWalkFunc func(name string, info os.FileInfo, r io.Reader) error
+
+//isArchive returns true if the url points on a supported archive
+func isArchive(u *url.URL) bool {
+	return isTarGz(u.Path) || isZip(u.Path)
+}
+
+func isTarGz(p string) bool {
+	return hasSuffixIgnoringCase(p, ".tar.gz") || hasSuffixIgnoringCase(p, ".tgz")
+}
+
+func isZip(p string) bool {
+	return hasSuffixIgnoringCase(p, ".zip")
+}
+
+//newRemoteScmHandler returns the handler corresponding to a remote location, archives
+// are downloaded while anything else is assumed to be a git repository
+func newRemoteScmHandler(l Logger, u *url.URL) (ScmHandler, error) {
+	if isArchive(u) {
+		return ArchiveScmHandler{Logger: l}, nil
+	}
+	return GitScmHandler{Logger: l}, nil
+}
+
+//Matches implements "github.com/ekara-platform/engine/component/scm.scmHandler
+func (archiveScm ArchiveScmHandler) Matches(u *url.URL, path string) bool {
+	// We always return false to force the archive to be fetched again
+	return false
+}
+
+//Fetch implements "github.com/ekara-platform/engine/component/scm.scmHandler
+func (archiveScm ArchiveScmHandler) Fetch(ctx context.Context, u *url.URL, path string, auth map[string]string) error {
+	source := u.String()
+	// The content is not the one of the recorded location until its extraction
+	os.Remove(archiveSourcePath(path))
+	archive, err := archiveScm.download(ctx, u, auth)
+	if err != nil {
+		return errors.New("unable to download archive " + source + ": " + err.Error())
+	}
+	defer os.Remove(archive)
+
+	archiveScm.Logger.Debug("extracting archive", UrlField(source), PathField(path))
+	err = extractArchive(ctx, archive, u.Path, path)
+	if err != nil {
+		return errors.New("unable to extract archive " + source + ": " + err.Error())
+	}
+	return ioutil.WriteFile(archiveSourcePath(path), []byte(source), 0644)
+}
+
+//archiveSourcePath returns the path of the file recording the location of the
+// archive extracted into the path
+func archiveSourcePath(path string) string {
+	return filepath.Clean(path) + archiveSourceSuffix
+}
+
+//matchesOffline implements offlineMatcher, the extracted archive being recognized
+// through the location recorded during its extraction
+func (archiveScm ArchiveScmHandler) matchesOffline(u *url.URL, path string) bool {
+	b, err := ioutil.ReadFile(archiveSourcePath(path))
+	return err == nil && string(b) == u.String()
+}
+
+//Update implements "github.com/ekara-platform/engine/component/scm.scmHandler
+func (archiveScm ArchiveScmHandler) Update(ctx context.Context, path string, auth map[string]string) (bool, error) {
+	// Doing nothing here and it's okay because Matches returns false
+	// then the archive will be fetched from scratch and never updated
+	return false, nil
+}
+
+//Switch implements "github.com/ekara-platform/engine/component/scm.scmHandler
+func (archiveScm ArchiveScmHandler) Switch(ctx context.Context, path string, ref string) error {
+	// Doing nothing here and it's okay because an archive holds a
+	// single version of the component then there is nothing to switch...
+	return nil
+}
+
+//download copies the archive into a temporary file and returns its path
+func (archiveScm ArchiveScmHandler) download(ctx context.Context, u *url.URL, auth map[string]string) (string, error) {
+	var in io.ReadCloser
+	switch u.Scheme {
+	case SchemeFile:
+		f, err := os.Open(u.Path)
+		if err != nil {
+			return "", err
+		}
+		in = f
+	case SchemeHttp, SchemeHttps:
+		archiveScm.Logger.Debug("downloading archive", UrlField(u.String()))
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		switch auth["method"] {
+		case "":
+		case "basic":
+			req.SetBasicAuth(auth["user"], auth["password"])
+		case "token":
+			req.Header.Set("Authorization", "Bearer "+auth["token"])
+		default:
+			return "", errors.New("unknown archive authentication method")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return "", fmt.Errorf("unexpected HTTP status %s", resp.Status)
+		}
+		in = resp.Body
+	default:
+		return "", fmt.Errorf("unsupported archive location: %s", u.String())
+	}
+	defer in.Close()
+
+	out, err := ioutil.TempFile("", "componentizer_archive")
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, in)
+	if err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+//extractArchive extracts the archive into the destination, the name is used to detect the archive type
+func extractArchive(ctx context.Context, archive string, name string, dst string) error {
+	walk := walkTarGz
+	if isZip(name) {
+		walk = walkZip
+	}
+
+	// First pass to detect a top-level folder to strip
+	var names []string
+	err := walk(archive, func(name string, info os.FileInfo, r io.Reader) error {
+		if name == "" {
+			return nil
+		}
+		if info.IsDir() {
+			name = name + "/"
+		}
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	strip := strippedFolder(names)
+
+	err = os.MkdirAll(dst, 0755)
+	if err != nil {
+		return err
+	}
+	return walk(archive, func(name string, info os.FileInfo, r io.Reader) error {
+		if err := canceled(ctx, "extraction"); err != nil {
+			return err
+		}
+		if strip != "" {
+			name = strings.TrimPrefix(strings.TrimPrefix(name, strip), "/")
+		}
+		if name == "" {
+			return nil
+		}
+		target := filepath.Join(dst, filepath.FromSlash(name))
+		if !strings.HasPrefix(target, filepath.Clean(dst)+string(os.PathSeparator)) {
+			return fmt.Errorf("illegal archive entry %s", name)
+		}
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		err := os.MkdirAll(filepath.Dir(target), 0755)
+		if err != nil {
+			return err
+		}
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm()|0600)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, r)
+		if e := out.Close(); err == nil {
+			err = e
+		}
+		return err
+	})
+}
+
+//strippedFolder returns the top-level folder holding all the given entries, if any
+func strippedFolder(names []string) string {
+	folder := ""
+	for _, name := range names {
+		parts := strings.SplitN(name, "/", 2)
+		if len(parts) == 1 && !strings.HasSuffix(name, "/") {
+			// There is a file at the top-level
+			return ""
+		}
+		if folder == "" {
+			folder = parts[0]
+		} else if folder != parts[0] {
+			return ""
+		}
+	}
+	return folder
+}
+
+func walkTarGz(archive string, fn archiveWalkFunc) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := cleanArchiveName(hdr.Name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = fn(name, hdr.FileInfo(), nil)
+		case tar.TypeReg, tar.TypeRegA:
+			err = fn(name, hdr.FileInfo(), tr)
+		default:
+			// Links and special files are skipped
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func walkZip(archive string, fn archiveWalkFunc) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		name := cleanArchiveName(f.Name)
+		info := f.FileInfo()
+		if info.IsDir() {
+			err = fn(name, info, nil)
+		} else if info.Mode().IsRegular() {
+			err = walkZipFile(f, name, fn)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkZipFile(f *zip.File, name string, fn archiveWalkFunc) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return fn(name, f.FileInfo(), r)
+}
+
+//cleanArchiveName returns the slash separated name of an archive entry, without any leading "./"
+func cleanArchiveName(name string) string {
+	name = path.Clean(strings.Replace(name, "\\", "/", -1))
+	name = strings.TrimPrefix(name, "/")
+	if name == "." {
+		return ""
+	}
+	return name
+}
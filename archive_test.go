@@ -0,0 +1,147 @@
+package componentizer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveTarGzStripped(t *testing.T) {
+	dir, err := ioutil.TempDir("", "componentizer_archive")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "comp.tar.gz")
+	writeTestTarGz(t, archive, map[string]string{
+		"comp-1.0/ekara.yaml":       "descriptor",
+		"comp-1.0/modules/main.yml": "module",
+	})
+	u, err := url.Parse("file://" + archive)
+	assert.Nil(t, err)
+
+	h, err := newLocalScmHandler(testLogger(), u)
+	assert.Nil(t, err)
+	if assert.IsType(t, ArchiveScmHandler{}, h) {
+		cPath := filepath.Join(dir, "comp")
+		assert.Nil(t, h.Fetch(context.Background(), u, cPath, nil))
+		assertTestFileContent(t, filepath.Join(cPath, "ekara.yaml"), "descriptor")
+		assertTestFileContent(t, filepath.Join(cPath, "modules", "main.yml"), "module")
+	}
+}
+
+func TestArchiveZipOverHttp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "componentizer_archive")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "comp.zip")
+	writeTestZip(t, archive, map[string]string{
+		"ekara.yaml":       "descriptor",
+		"modules/main.yml": "module",
+	})
+	srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/comp.zip")
+	assert.Nil(t, err)
+
+	h, err := newRemoteScmHandler(testLogger(), u)
+	assert.Nil(t, err)
+	if assert.IsType(t, ArchiveScmHandler{}, h) {
+		cPath := filepath.Join(dir, "comp")
+		assert.Nil(t, h.Fetch(context.Background(), u, cPath, nil))
+		assertTestFileContent(t, filepath.Join(cPath, "ekara.yaml"), "descriptor")
+		assertTestFileContent(t, filepath.Join(cPath, "modules", "main.yml"), "module")
+	}
+
+	missing, err := url.Parse(srv.URL + "/missing.zip")
+	assert.Nil(t, err)
+	assert.NotNil(t, h.Fetch(context.Background(), missing, filepath.Join(dir, "missing"), nil))
+}
+
+func TestArchiveOffline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "componentizer_archive")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	writeTestZip(t, filepath.Join(dir, "comp.zip"), map[string]string{"ekara.yaml": "descriptor"})
+	srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/comp.zip")
+	assert.Nil(t, err)
+	repo, err := CreateRepository(u.String(), "", nil)
+	assert.Nil(t, err)
+	c := testComponent{id: "comp", repo: repo}
+	h := ArchiveScmHandler{Logger: testLogger()}
+	compDir := filepath.Join(dir, "components")
+
+	// The archive can't be fetched offline
+	_, err = fetchThroughSCM(c, h, u, compDir, nil, fetchSettings{offline: true})(context.Background())
+	assert.True(t, errors.Is(err, errNotAvailableOffline))
+
+	// Once extracted, it is reused offline
+	_, err = fetchThroughSCM(c, h, u, compDir, nil, fetchSettings{})(context.Background())
+	assert.Nil(t, err)
+	// The location of the archive is recorded out of the component content
+	files, err := ioutil.ReadDir(filepath.Join(compDir, "comp"))
+	if assert.Nil(t, err) && assert.Len(t, files, 1) {
+		assert.Equal(t, "ekara.yaml", files[0].Name())
+	}
+	srv.Close()
+	fComp, err := fetchThroughSCM(c, h, u, compDir, nil, fetchSettings{offline: true})(context.Background())
+	if assert.Nil(t, err) {
+		assertTestFileContent(t, filepath.Join(fComp.rootPath, "ekara.yaml"), "descriptor")
+	}
+
+	// But not as the content of another archive
+	other, err := url.Parse(srv.URL + "/other.zip")
+	assert.Nil(t, err)
+	_, err = fetchThroughSCM(c, h, other, compDir, nil, fetchSettings{offline: true})(context.Background())
+	assert.True(t, errors.Is(err, errNotAvailableOffline))
+}
+
+func writeTestTarGz(t *testing.T, path string, files map[string]string) {
+	f, err := os.Create(path)
+	assert.Nil(t, err)
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	defer gz.Close()
+	tw := tar.NewWriter(gz)
+	defer tw.Close()
+	for name, content := range files {
+		assert.Nil(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err = tw.Write([]byte(content))
+		assert.Nil(t, err)
+	}
+}
+
+func writeTestZip(t *testing.T, path string, files map[string]string) {
+	f, err := os.Create(path)
+	assert.Nil(t, err)
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	defer zw.Close()
+	for name, content := range files {
+		w, err := zw.Create(name)
+		assert.Nil(t, err)
+		_, err = w.Write([]byte(content))
+		assert.Nil(t, err)
+	}
+}
+
+func assertTestFileContent(t *testing.T, path string, content string) {
+	b, err := ioutil.ReadFile(path)
+	if assert.Nil(t, err) {
+		assert.Equal(t, content, string(b))
+	}
+}
@@ -6,6 +6,23 @@ type (
 		Merge(with Model) (Model, error)
 	}
 
+	//Diffable is implemented by the models able to tell how they differ from another
+	// model, it is used to describe the model changes between two resolutions
+	Diffable interface {
+		//Diff returns the changes required to turn the model into the given one
+		Diff(to Model) ([]ModelChange, error)
+	}
+
+	//ModelChange is a difference between two models
+	ModelChange struct {
+		// Path locates the changed value into the model
+		Path string
+		// From is the value into the original model, nil if the value has been added
+		From interface{}
+		// To is the value into the other model, nil if the value has been removed
+		To interface{}
+	}
+
 	Component interface {
 		ComponentRef
 		GetRepository() Repository
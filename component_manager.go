@@ -1,17 +1,32 @@
 package componentizer
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"net/url"
 	"os"
+	"sort"
+	"sync"
+	"time"
 )
 
 type (
 	//ComponentManager is the facade for accessing components.
+	//
+	//A component manager is safe for concurrent use, concurrent initializations
+	// being executed one after the other.
+	//
+	//The methods accepting a context stop as soon as possible once the context is
+	// done, returning an error wrapping the context error.
 	ComponentManager interface {
 		//Init initialize the component manager with the specified main component
 		Init(main Component, tplC TemplateContext) (Model, error)
 
+		//InitContext is like Init but the fetches and the templating are interrupted
+		// when the context is done, the partially fetched components being removed
+		InitContext(ctx context.Context, main Component, tplC TemplateContext) (Model, error)
+
 		//ContainsFile returns paths pointing on templated components containing the given file
 		//	Parameters
 		//		name: the name of the file to search
@@ -20,6 +35,11 @@ type (
 		//          the Finder will look into all the components available into the platform.
 		ContainsFile(name string, tplC TemplateContext, in ...ComponentRef) MatchingPaths
 
+		//ContainsFileContext is like ContainsFile but the search is interrupted when the
+		// context is done, the matching paths already found being released and an error
+		// wrapping the context error being returned
+		ContainsFileContext(ctx context.Context, name string, tplC TemplateContext, in ...ComponentRef) (MatchingPaths, error)
+
 		//ContainsDirectory returns paths pointing on templated components containing the given directory
 		//	Parameters
 		//		name: the name of the directory to search
@@ -28,109 +48,367 @@ type (
 		//          the Finder will look into all the components available into the platform.
 		ContainsDirectory(name string, tplC TemplateContext, in ...ComponentRef) MatchingPaths
 
+		//ContainsDirectoryContext is like ContainsDirectory but the search is interrupted when
+		// the context is done, the matching paths already found being released and an error
+		// wrapping the context error being returned
+		ContainsDirectoryContext(ctx context.Context, name string, tplC TemplateContext, in ...ComponentRef) (MatchingPaths, error)
+
+		//Plan computes what an initialization with the specified main component would do
+		// to the work directory, without modifying it. The main component and its parents
+		// are fetched into a temporary directory while the other components are inspected
+		// remotely, when supported by their SCM handler.
+		Plan(main Component, tplC TemplateContext) (Plan, error)
+
+		//PlanContext is like Plan but the fetches and the inspections are interrupted
+		// when the context is done
+		PlanContext(ctx context.Context, main Component, tplC TemplateContext) (Plan, error)
+
+		//Diff runs two resolutions of the specified main component, the first one with
+		// the "from" options and the second one with the "to" options, and returns
+		// how they differ. The resolutions take place into temporary directories, the
+		// work directory being left untouched.
+		//
+		//Comparing the current lock with the latest references is done with
+		// WithLockfile as "from" option and WithoutLockfile as "to" option.
+		Diff(main Component, tplC TemplateContext, from []ManagerOption, to []ManagerOption) (ResolutionDiff, error)
+
+		//DiffContext is like Diff but the resolutions are interrupted when the context is done
+		DiffContext(ctx context.Context, main Component, tplC TemplateContext, from []ManagerOption, to []ManagerOption) (ResolutionDiff, error)
+
+		//Refresh updates the local content of an available component, parses its model
+		// again and merges the models of the components in the parsing order of the last
+		// initialization. It returns true if the final model changed.
+		//
+		//The components referenced by the refreshed component are not discovered again,
+		// an initialization is required to take into account new references.
+		Refresh(cr ComponentRef) (bool, error)
+
+		//RefreshContext is like Refresh but the update is interrupted when the context is done
+		RefreshContext(ctx context.Context, cr ComponentRef) (bool, error)
+
+		//Watch monitors the source directories of the available components located with
+		// the file scheme and refreshes the components changed into them, the changes
+		// being debounced for the given duration. An event is sent to the returned channel
+		// after each refresh, the channel being closed once the context is done.
+		//
+		//The working trees of the local git repositories are copied, their uncommitted
+		// changes being picked up while the changes of their git directory are ignored.
+		// Each event holds the refreshed components templated again, which must be
+		// released. File system notifications are used on Linux while the source
+		// directories are polled on the other platforms.
+		Watch(ctx context.Context, debounce time.Duration) (<-chan WatchEvent, error)
+
 		//IsAvailable checks if a component is locally available
 		IsAvailable(cr ComponentRef) bool
 
 		// ComponentOrder returns a slice of component identifiers in the parsing order
 		ComponentOrder() []string
 
+		//Model returns the final model of the last initialization, merged again by the
+		// subsequent refreshes
+		Model() Model
+
+		//Resolved returns how a locally available component has been fetched
+		Resolved(cr ComponentRef) (FetchResult, bool)
+
+		//Lock returns the lockfile recording the revisions of the fetched components
+		Lock() Lockfile
+
+		//Graph returns the components discovered during the last initialization and
+		// the relationships through which they have been discovered
+		Graph() Graph
+
+		//Explain tells why the component with the given identifier is part of the
+		// last initialization
+		Explain(id string) (Explanation, error)
+
 		//Use returns a component matching the given reference.
 		//If the component corresponding to the reference contains a template
 		//definition then the component will be duplicated and templated before
 		// being returned as a UsableComponent.
 		// Don't forget to Release the UsableComponent once is processing is over...
 		Use(cr ComponentRef, tplC TemplateContext) (UsableComponent, error)
+
+		//UseContext is like Use but the templating is interrupted when the context is
+		// done, the partially templated copy being removed
+		UseContext(ctx context.Context, cr ComponentRef, tplC TemplateContext) (UsableComponent, error)
 	}
 
 	componentManager struct {
-		l         *log.Logger
-		directory string
-		fComps    map[string]fetchedComponent
-		order     []string
+		l           Logger
+		directory   string
+		scmHandlers map[string]ScmHandlerFactory
+		credentials []CredentialProvider
+		lock        *Lockfile
+		offline     bool
+		cacheDir    string
+		rewrites    []RewriteRule
+		fallbacks   []FallbackRule
+		workers     int
+		observers   []Observer
+		// strictLock makes the components without a matching lock fail
+		strictLock bool
+		// maxParentDepth is the maximum number of parents above the main component, if positive
+		maxParentDepth int
+		// initMu serializes the initializations
+		initMu sync.Mutex
+		// mu guards the fetched, being fetched and missing components and the order
+		mu       sync.RWMutex
+		missing  []MissingComponent
+		fComps   map[string]fetchedComponent
+		inflight map[string]*fetchCall
+		order    []string
+		// models holds the models parsed during the last initialization, by component id
+		models map[string]Model
+		// model is the final model of the last initialization
+		model Model
+		// discovery records what has been discovered during the last initialization
+		discovery *discovery
+	}
+
+	//fetchCall is a fetch in progress, shared by the concurrent fetches of a component
+	fetchCall struct {
+		done  chan struct{}
+		fComp fetchedComponent
+		err   error
 	}
 
 	fetchedComponent struct {
 		id        string
 		rootPath  string
 		component Component
+		// changed is true if the component content has been fetched or updated
+		changed bool
+		// fresh is true if the component has been fetched from scratch
+		fresh bool
+		// ref is the reference the component has been switched to
+		ref string
+		// revision is the exact revision of the component, if known
+		revision string
+		// location is the location the component has been fetched from
+		location *url.URL
+		// source is the component as it has been fetched, component being resolved
+		// again from the final model afterwards
+		source Component
+	}
+
+	//FetchResult describes how a component has been made available locally
+	FetchResult struct {
+		// Id is the component identifier
+		Id string
+		// Repository is the repository of the component
+		Repository Repository
+		// Location is the location the component has been effectively fetched
+		// from, after the rewrite rules and the eventual fallbacks
+		Location *url.URL
+		// Ref is the reference resolved from the repository one, a version
+		// range being resolved into the matching tag
+		Ref string
+		// Revision is the exact revision of the component, as a commit hash,
+		// or empty if the SCM handler is not able to identify it
+		Revision string
+		// Path is the local path of the fetched component
+		Path string
 	}
 )
 
-//createComponentManager creates a new component manager
-func CreateComponentManager(l *log.Logger, workDir string) ComponentManager {
-	return &componentManager{
-		l:         l,
-		directory: workDir,
-		fComps:    map[string]fetchedComponent{},
-		order:     []string{},
+//defaultFetchWorkers is the default number of components fetched concurrently
+const defaultFetchWorkers = 4
+
+//CreateComponentManager creates a new component manager
+//
+//A nil logger discards all the messages.
+func CreateComponentManager(l Logger, workDir string, opts ...ManagerOption) ComponentManager {
+	if l == nil {
+		l = NopLogger()
+	}
+	cm := &componentManager{
+		l:           l,
+		directory:   workDir,
+		scmHandlers: map[string]ScmHandlerFactory{},
+		workers:     defaultFetchWorkers,
+		fComps:      map[string]fetchedComponent{},
+		inflight:    map[string]*fetchCall{},
+		order:       []string{},
+	}
+	for _, opt := range opts {
+		opt(cm)
+	}
+	return cm
+}
+
+//derive returns a component manager with the same settings, eventually customized
+// by the options, but working into the given directory
+func (cm *componentManager) derive(dir string, opts ...ManagerOption) *componentManager {
+	dm := &componentManager{
+		l:              cm.l,
+		directory:      dir,
+		scmHandlers:    map[string]ScmHandlerFactory{},
+		credentials:    append([]CredentialProvider{}, cm.credentials...),
+		lock:           cm.lock,
+		strictLock:     cm.strictLock,
+		offline:        cm.offline,
+		cacheDir:       cm.cacheDir,
+		rewrites:       append([]RewriteRule{}, cm.rewrites...),
+		fallbacks:      append([]FallbackRule{}, cm.fallbacks...),
+		workers:        cm.workers,
+		maxParentDepth: cm.maxParentDepth,
+		fComps:         map[string]fetchedComponent{},
+		inflight:       map[string]*fetchCall{},
+		order:          []string{},
+	}
+	for scheme, f := range cm.scmHandlers {
+		dm.scmHandlers[scheme] = f
 	}
+	for _, opt := range opts {
+		opt(dm)
+	}
+	return dm
 }
 
 func (cm *componentManager) Init(main Component, tplC TemplateContext) (Model, error) {
+	return cm.InitContext(context.Background(), main, tplC)
+}
+
+func (cm *componentManager) InitContext(ctx context.Context, main Component, tplC TemplateContext) (Model, error) {
+	cm.initMu.Lock()
+	defer cm.initMu.Unlock()
+
+	cm.mu.Lock()
+	cm.missing = nil
+	cm.mu.Unlock()
+	start := time.Now()
+
 	// Compute a temporary model with only the parents to find components
-	tempModel, comps, err := cm.findComponents(main, tplC)
+	cm.notify(Event{Type: EventComponentDiscovered, ComponentId: main.ComponentId(), Url: locationString(main.GetRepository()), Ref: main.GetRepository().Ref})
+	d := newDiscovery(main, tplC)
+	tempModel, comps, err := cm.findComponents(ctx, main, tplC, nil, d)
 	if err != nil {
 		return nil, err
 	}
 
-	// Go through found components to build the final model in order
-	var fModel Model
-	for _, comp := range comps {
-		// Check if the component is referenced from the model
-		if tempModel.IsReferenced(comp) {
-			// Refresh component by resolving it again (takes into account overrides after first discovery)
-			comp, err := comp.Component(tempModel)
-			if err != nil {
-				return nil, err
-			}
+	// Keep only the components referenced from the model
+	retained, err := retainComponents(tempModel, comps, d)
+	if err != nil {
+		return nil, err
+	}
 
-			// Fetch the component if necessary
-			fComp, err := cm.fetchComponent(comp)
-			if err != nil {
-				return nil, err
-			}
+	// Fetch the components concurrently if necessary
+	fComps, errs := cm.fetchComponents(ctx, retained)
 
-			// Parse the component model to merge it into the final model
-			cModel, err := comp.ParseModel(fComp.rootPath, tplC)
-			if err != nil {
-				return nil, err
-			}
-			if cModel != nil {
-				if fModel != nil {
-					fModel, err = fModel.Merge(cModel)
-					if err != nil {
-						return nil, err
-					}
-				} else {
-					fModel = cModel
+	// Go through retained components to build the final model in order
+	var fModel Model
+	var order []string
+	models := map[string]Model{}
+	for i, comp := range retained {
+		if errors.Is(errs[i], errNotAvailableOffline) {
+			// Keep going to report all the missing components
+			continue
+		}
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if err := canceled(ctx, "initialization"); err != nil {
+			return nil, err
+		}
+
+		// Parse the component model to merge it into the final model
+		cModel, err := comp.ParseModel(fComps[i].rootPath, tplC)
+		if err != nil {
+			return nil, err
+		}
+		cm.notify(Event{Type: EventModelParsed, ComponentId: comp.ComponentId(), Path: fComps[i].rootPath})
+		models[comp.ComponentId()] = cModel
+		if cModel != nil {
+			if fModel != nil {
+				fModel, err = fModel.Merge(cModel)
+				if err != nil {
+					return nil, err
 				}
+			} else {
+				fModel = cModel
 			}
-
-			// Add the component id to the ordered list
-			cm.order = append(cm.order, comp.ComponentId())
 		}
+
+		// Add the component id to the ordered list
+		order = append(order, comp.ComponentId())
+	}
+
+	err = cm.complete(fModel, order, models, d)
+	if err != nil {
+		return nil, err
+	}
+	cm.notify(Event{Type: EventMergeDone, ComponentId: main.ComponentId(), Duration: time.Since(start)})
+	return fModel, nil
+}
+
+//complete records the order and the models of the components parsed during an
+// initialization and what has been discovered, and refreshes the fetched components
+// from the final model
+func (cm *componentManager) complete(fModel Model, order []string, models map[string]Model, d *discovery) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if len(cm.missing) > 0 {
+		return &OfflineError{Missing: cm.missing}
 	}
+	cm.order = order
+	cm.models = models
+	cm.model = fModel
+	cm.discovery = d
+	return cm.resolveFetched(fModel)
+}
 
-	// Update fetched components with refreshed components from the model
+//resolveFetched updates the fetched components with the components resolved from
+// the final model, the caller holding the lock
+func (cm *componentManager) resolveFetched(fModel Model) error {
 	for fId, fComp := range cm.fComps {
-		fComp.component, err = fComp.component.Component(fModel)
+		var err error
+		fComp.component, err = fComp.source.Component(fModel)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		cm.fComps[fId] = fComp
 	}
+	return nil
+}
 
-	return fModel, nil
+//retainComponents returns the components referenced from the model, resolved again
+// from the model
+func retainComponents(tempModel Model, comps []Component, d *discovery) ([]Component, error) {
+	var retained []Component
+	for _, comp := range comps {
+		if tempModel.IsReferenced(comp) {
+			// Refresh component by resolving it again (takes into account overrides after first discovery)
+			comp, err := comp.Component(tempModel)
+			if err != nil {
+				return nil, err
+			}
+			retained = append(retained, comp)
+			err = d.retain(comp)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return retained, nil
 }
 
-func (cm componentManager) findComponents(comp Component, tplC TemplateContext) (Model, []Component, error) {
+//findComponents fetches the component and discovers recursively its parents and the
+// components they reference, the chain holding the components from the main one
+// to the child of the component and the discovery recording what has been found
+func (cm *componentManager) findComponents(ctx context.Context, comp Component, tplC TemplateContext, chain []ChainedComponent, d *discovery) (Model, []Component, error) {
 	var fModel Model
 	var comps []Component
+	chain = append(chain, ChainedComponent{Id: comp.ComponentId(), Repository: comp.GetRepository()})
 
 	// Fetch component
-	fComp, err := cm.fetchComponent(comp)
+	fComp, err := cm.fetchComponent(ctx, comp)
+	if errors.Is(err, errNotAvailableOffline) {
+		// Nothing can be discovered from a missing component
+		return nil, nil, nil
+	}
 	if err != nil {
-		cm.l.Printf("error fetching the main descriptor %s", err.Error())
+		cm.l.Error("error fetching the main descriptor", ComponentField(comp.ComponentId()), ErrorField(err))
 		return nil, nil, err
 	}
 
@@ -139,11 +417,23 @@ func (cm componentManager) findComponents(comp Component, tplC TemplateContext)
 	if err != nil {
 		return nil, nil, err
 	}
+	if parent != nil {
+		err = cm.checkParent(chain, parent)
+		if err != nil {
+			return nil, nil, err
+		}
+		cm.notifyDiscovered(comp, parent)
+		d.discover(comp, parent, EdgeParent)
+	}
+	for _, other := range otherComps {
+		cm.notifyDiscovered(comp, other)
+		d.discover(comp, other, EdgeReference)
+	}
 
 	// Go through parents recursively
 	if parent != nil {
 		var pComps []Component
-		fModel, pComps, err = cm.findComponents(parent, tplC)
+		fModel, pComps, err = cm.findComponents(ctx, parent, tplC, chain, d)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -159,6 +449,7 @@ func (cm componentManager) findComponents(comp Component, tplC TemplateContext)
 	if err != nil {
 		return nil, nil, err
 	}
+	d.parsed(comp.ComponentId(), cModel)
 	if cModel != nil {
 		if fModel != nil {
 			fModel, err = fModel.Merge(cModel)
@@ -173,41 +464,110 @@ func (cm componentManager) findComponents(comp Component, tplC TemplateContext)
 	return fModel, comps, nil
 }
 
-func (cm componentManager) ContainsFile(name string, tplC TemplateContext, in ...ComponentRef) MatchingPaths {
-	return cm.contains(false, name, tplC, in...)
+func (cm *componentManager) ContainsFile(name string, tplC TemplateContext, in ...ComponentRef) MatchingPaths {
+	// The background context is never canceled
+	res, _ := cm.contains(context.Background(), false, name, tplC, in...)
+	return res
+}
+
+func (cm *componentManager) ContainsFileContext(ctx context.Context, name string, tplC TemplateContext, in ...ComponentRef) (MatchingPaths, error) {
+	return cm.contains(ctx, false, name, tplC, in...)
+}
+
+func (cm *componentManager) ContainsDirectory(name string, tplC TemplateContext, in ...ComponentRef) MatchingPaths {
+	// The background context is never canceled
+	res, _ := cm.contains(context.Background(), true, name, tplC, in...)
+	return res
 }
 
-func (cm componentManager) ContainsDirectory(name string, tplC TemplateContext, in ...ComponentRef) MatchingPaths {
-	return cm.contains(true, name, tplC, in...)
+func (cm *componentManager) ContainsDirectoryContext(ctx context.Context, name string, tplC TemplateContext, in ...ComponentRef) (MatchingPaths, error) {
+	return cm.contains(ctx, true, name, tplC, in...)
 }
 
-func (cm componentManager) IsAvailable(cr ComponentRef) bool {
+func (cm *componentManager) IsAvailable(cr ComponentRef) bool {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
 	_, ok := cm.fComps[cr.ComponentId()]
 	return ok
 }
 
-func (cm componentManager) ComponentOrder() []string {
-	return cm.order
+func (cm *componentManager) ComponentOrder() []string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	order := make([]string, len(cm.order))
+	copy(order, cm.order)
+	return order
+}
+
+func (cm *componentManager) Model() Model {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.model
+}
+
+func (cm *componentManager) Resolved(cr ComponentRef) (FetchResult, bool) {
+	fComp, ok := cm.isComponentFetched(cr.ComponentId())
+	if !ok {
+		return FetchResult{}, false
+	}
+	return fComp.result(), true
+}
+
+func (cm *componentManager) Lock() Lockfile {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	l := Lockfile{}
+	for _, fComp := range cm.fComps {
+		// The lock must match the repository effectively fetched
+		repo := fComp.source.GetRepository()
+		lc := LockedComponent{
+			Id:       fComp.id,
+			Ref:      repo.Ref,
+			Revision: fComp.revision,
+		}
+		if repo.Loc != nil {
+			lc.Url = repo.Loc.String()
+		}
+		l.Components = append(l.Components, lc)
+	}
+	sort.Slice(l.Components, func(i, j int) bool {
+		return l.Components[i].Id < l.Components[j].Id
+	})
+	return l
+}
+
+func (cm *componentManager) Graph() Graph {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	if cm.discovery == nil {
+		return Graph{}
+	}
+	return cm.discovery.graph.copy()
+}
+
+func (cm *componentManager) Use(cr ComponentRef, tplC TemplateContext) (UsableComponent, error) {
+	return cm.UseContext(context.Background(), cr, tplC)
 }
 
-func (cm componentManager) Use(cr ComponentRef, tplC TemplateContext) (UsableComponent, error) {
+func (cm *componentManager) UseContext(ctx context.Context, cr ComponentRef, tplC TemplateContext) (UsableComponent, error) {
 	var res usable
-	fetchedC, ok := cm.fComps[cr.ComponentId()]
+	fetchedC, ok := cm.isComponentFetched(cr.ComponentId())
 	if !ok {
 		return nil, fmt.Errorf("component %s is not available", cr.ComponentId())
 	}
 	if ok, patterns := fetchedC.component.GetTemplates(); ok {
-		templatedPath, err := executeTemplate(fetchedC.rootPath, patterns, tplC.Clone(cr))
+		templatedPath, err := executeTemplate(ctx, fetchedC.rootPath, patterns, tplC.Clone(cr))
 		if err != nil {
 			return usable{}, err
 		}
 
 		if templatedPath != "" {
 			// Path has a value, the component has been templated
+			cm.notify(Event{Type: EventTemplatedCopyCreated, ComponentId: cr.ComponentId(), Path: templatedPath})
 			res = usable{
 				id:        cr.ComponentId(),
 				path:      templatedPath,
-				release:   cm.cleanup(templatedPath),
+				release:   cm.cleanup(cr.ComponentId(), templatedPath),
 				templated: true,
 				source:    cr,
 			}
@@ -231,84 +591,265 @@ func (cm componentManager) Use(cr ComponentRef, tplC TemplateContext) (UsableCom
 	return res, nil
 }
 
-func (cm componentManager) contains(isFolder bool, name string, tplC TemplateContext, in ...ComponentRef) MatchingPaths {
+//contains looks for the file or the directory into the components, the matching paths
+// already found being released if the context is done before the end of the search
+func (cm *componentManager) contains(ctx context.Context, isFolder bool, name string, tplC TemplateContext, in ...ComponentRef) (MatchingPaths, error) {
 	res := MatchingPaths{
 		Paths: make([]MatchingPath, 0, 0),
 	}
-	if len(in) > 0 {
-		for _, cRef := range in {
-			if match, b := cm.checkMatch(cRef, tplC, name, isFolder); b {
-				res.Paths = append(res.Paths, match)
-			}
+	refs := in
+	if len(refs) == 0 {
+		for _, comp := range cm.fetchedComponents() {
+			refs = append(refs, comp.component)
 		}
-	} else {
-		for _, comp := range cm.fComps {
-			if match, b := cm.checkMatch(comp.component, tplC, name, isFolder); b {
+	}
+	for _, cRef := range refs {
+		err := canceled(ctx, "search of "+name)
+		if err == nil {
+			var match MatchingPath
+			var b bool
+			match, b, err = cm.checkMatch(ctx, cRef, tplC, name, isFolder)
+			if b {
 				res.Paths = append(res.Paths, match)
 			}
 		}
+		if err != nil {
+			res.Release()
+			return MatchingPaths{Paths: make([]MatchingPath, 0, 0)}, err
+		}
 	}
-	return res
+	return res, nil
 }
 
 func (cm *componentManager) isComponentFetched(id string) (val fetchedComponent, present bool) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
 	val, present = cm.fComps[id]
 	return
 }
 
-func (cm *componentManager) fetchComponent(c Component) (fetchedComponent, error) {
-	fComp, isFetched := cm.isComponentFetched(c.ComponentId())
-	if !isFetched {
-		cm.l.Printf("Fetching component %s", c.ComponentId())
+//fetchedComponents returns a snapshot of the fetched components
+func (cm *componentManager) fetchedComponents() []fetchedComponent {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	res := make([]fetchedComponent, 0, len(cm.fComps))
+	for _, fComp := range cm.fComps {
+		res = append(res, fComp)
+	}
+	return res
+}
+
+//fetchComponents fetches the components using at most the configured number of
+// workers, the results and errors are in the order of the components
+func (cm *componentManager) fetchComponents(ctx context.Context, comps []Component) ([]fetchedComponent, []error) {
+	fComps := make([]fetchedComponent, len(comps))
+	errs := make([]error, len(comps))
+	workers := cm.workers
+	if workers < 1 {
+		workers = 1
+	}
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, comp := range comps {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, comp Component) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fComps[i], errs[i] = cm.fetchComponent(ctx, comp)
+		}(i, comp)
+	}
+	wg.Wait()
+	return fComps, errs
+}
+
+//fetchComponent fetches the component if necessary, concurrent fetches of the
+// same component are done only once
+func (cm *componentManager) fetchComponent(ctx context.Context, c Component) (fetchedComponent, error) {
+	id := c.ComponentId()
+	cm.mu.Lock()
+	if fComp, ok := cm.fComps[id]; ok {
+		cm.mu.Unlock()
+		return fComp, nil
+	}
+	if call, ok := cm.inflight[id]; ok {
+		cm.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.fComp, call.err
+		case <-ctx.Done():
+			return fetchedComponent{}, canceled(ctx, "fetch of component "+id)
+		}
+	}
+	call := &fetchCall{done: make(chan struct{})}
+	cm.inflight[id] = call
+	cm.mu.Unlock()
+
+	call.fComp, call.err = cm.doFetchComponent(ctx, c, false)
+
+	cm.mu.Lock()
+	delete(cm.inflight, id)
+	if call.err == nil {
+		cm.fComps[id] = call.fComp
+	}
+	cm.mu.Unlock()
+	close(call.done)
+	return call.fComp, call.err
+}
+
+//doFetchComponent fetches the component from its locations, the working trees of the local
+// git repositories being copied instead of cloned if workTree is true
+func (cm *componentManager) doFetchComponent(ctx context.Context, c Component, workTree bool) (fetchedComponent, error) {
+	locs, err := cm.candidateLocations(c.GetRepository())
+	if err != nil {
+		return fetchedComponent{}, err
+	}
+
+	s, err := cm.fetchSettings(c)
+	if err != nil {
+		return fetchedComponent{}, err
+	}
+	s.workTree = workTree
+
+	var fComp fetchedComponent
+	var failures []locationFailure
+	start := time.Now()
+	for i, loc := range locs {
+		fields := []Field{ComponentField(c.ComponentId()), UrlField(loc.String()), RefField(c.GetRepository().Ref)}
+		if i == 0 {
+			cm.l.Info("fetching component", fields...)
+		} else {
+			cm.l.Warn("fetching component from fallback", fields...)
+		}
 
 		// Resolve fetch handler
-		h, err := GetScmHandler(cm.l, cm.directory, c)
+		cm.notify(Event{Type: EventFetchStarted, ComponentId: c.ComponentId(), Url: loc.String(), Ref: c.GetRepository().Ref})
+		attempt := time.Now()
+		s.loc = loc
+		var h Handler
+		h, err = getScmHandler(ctx, cm.l, cm.directory, c, s)
 		if err != nil {
-			return fetchedComponent{}, err
+			cm.l.Error("error fetching the component", append(fields, ErrorField(err))...)
+			cm.notifyFetched(c, loc, attempt, fetchedComponent{}, err)
+			if ctx.Err() != nil {
+				break
+			}
+			failures = append(failures, locationFailure{loc: loc, err: err})
+			continue
 		}
 
 		// Do the fetching
-		fComp, err = h()
-		if err != nil {
-			cm.l.Printf("error fetching the component: %s", err.Error())
-			return fetchedComponent{}, err
+		fComp, err = h(ctx)
+		cm.notifyFetched(c, loc, attempt, fComp, err)
+		if err == nil || ctx.Err() != nil {
+			break
 		}
-
-		// Register the component
-		fComp.component = c
-		cm.fComps[c.ComponentId()] = fComp
-		cm.l.Printf("Component %s is available in %s", c.ComponentId(), fComp.rootPath)
+		cm.l.Error("error fetching the component", append(fields, ErrorField(err))...)
+		failures = append(failures, locationFailure{loc: loc, err: err})
+		if !isUnavailable(err) {
+			// The fallback locations would fail the same way
+			break
+		}
+	}
+	if err != nil && ctx.Err() == nil && len(failures) > 1 {
+		err = &fallbackError{failures: failures}
+	}
+	if errors.Is(err, errNotAvailableOffline) {
+		cm.l.Warn("component not available offline", ComponentField(c.ComponentId()))
+		cm.addMissing(c)
+		return fetchedComponent{}, err
+	}
+	if err != nil {
+		return fetchedComponent{}, err
 	}
+
+	fComp.component = c
+	fComp.source = c
+	cm.l.Info("component fetched", ComponentField(c.ComponentId()), UrlField(fComp.location.String()), RefField(fComp.ref), PathField(fComp.rootPath), DurationField(time.Since(start)))
 	return fComp, nil
 }
 
-func (cm componentManager) checkMatch(r ComponentRef, tplC TemplateContext, name string, isFolder bool) (MatchingPath, bool) {
-	uv, err := cm.Use(r, tplC)
+func (fc fetchedComponent) result() FetchResult {
+	return FetchResult{
+		Id:         fc.id,
+		Repository: fc.source.GetRepository(),
+		Location:   fc.location,
+		Ref:        fc.ref,
+		Revision:   fc.revision,
+		Path:       fc.rootPath,
+	}
+}
+
+//fetchSettings returns the settings to use to fetch the component, failing if the
+// component has no matching lock into a strict lockfile
+func (cm *componentManager) fetchSettings(c Component) (fetchSettings, error) {
+	s := fetchSettings{
+		handlers:    cm.scmHandlers,
+		credentials: cm.credentials,
+		offline:     cm.offline,
+		cacheDir:    cm.cacheDir,
+	}
+	if cm.lock != nil {
+		locked, ok := cm.lock.Get(c.ComponentId())
+		switch {
+		case ok && locked.matches(c.GetRepository()):
+			s.revision = locked.Revision
+		case cm.strictLock:
+			return s, &UnlockedComponentError{Id: c.ComponentId(), Outdated: ok}
+		case ok:
+			cm.l.Warn("ignoring outdated lock", ComponentField(c.ComponentId()))
+		}
+	}
+	return s, nil
+}
+
+//addMissing records a component missing from the work directory, only once
+func (cm *componentManager) addMissing(c Component) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	for _, m := range cm.missing {
+		if m.Id == c.ComponentId() {
+			return
+		}
+	}
+	cm.missing = append(cm.missing, MissingComponent{Id: c.ComponentId(), Repository: c.GetRepository()})
+}
+
+//checkMatch looks for the file or the directory into the component, only the
+// cancellation of the context being reported as an error
+func (cm *componentManager) checkMatch(ctx context.Context, r ComponentRef, tplC TemplateContext, name string, isFolder bool) (MatchingPath, bool, error) {
+	uv, err := cm.UseContext(ctx, r, tplC)
 	if err != nil {
-		cm.l.Printf("An error occurred using the component %s : %s", r.ComponentId(), err.Error())
-		return mPath{}, false
+		if ctx.Err() != nil {
+			return mPath{}, false, canceled(ctx, "search of "+name)
+		}
+		cm.l.Error("error using the component", ComponentField(r.ComponentId()), ErrorField(err))
+		return mPath{}, false, nil
 	}
 	if isFolder {
 		if ok, match := uv.ContainsDirectory(name); ok {
-			return match, true
+			return match, true, nil
 		} else {
 			uv.Release()
 		}
 	} else {
 		if ok, match := uv.ContainsFile(name); ok {
-			return match, true
+			return match, true, nil
 		} else {
 			uv.Release()
 		}
 	}
-	return mPath{}, false
+	return mPath{}, false, nil
 }
 
-func (cm componentManager) cleanup(path string) func() {
+func (cm *componentManager) cleanup(id string, path string) func() {
 	return func() {
 		err := os.RemoveAll(path)
 		if err != nil {
-			cm.l.Printf("Unable to clean temporary component path %s: %s", path, err.Error())
+			cm.l.Warn("unable to clean temporary component path", PathField(path), ErrorField(err))
 		}
+		cm.notify(Event{Type: EventTemplatedCopyReleased, ComponentId: id, Path: path, Err: err})
 	}
 }
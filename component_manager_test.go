@@ -0,0 +1,424 @@
+package componentizer
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	testDescriptor struct {
+		Parent     *testDescriptorRef  `yaml:"parent"`
+		Components []testDescriptorRef `yaml:"components"`
+		Value      string              `yaml:"value"`
+		// Overrides replaces the location of components, by id
+		Overrides map[string]string `yaml:"overrides"`
+	}
+
+	testDescriptorRef struct {
+		Id           string `yaml:"id"`
+		Loc          string `yaml:"loc"`
+		Ref          string `yaml:"ref"`
+		Unreferenced bool   `yaml:"unreferenced"`
+	}
+
+	testComponent struct {
+		id   string
+		repo Repository
+	}
+
+	testModel struct {
+		values       map[string]string
+		unreferenced map[string]bool
+		overrides    map[string]string
+	}
+
+	testTemplateContext struct{}
+)
+
+func (c testComponent) ComponentId() string {
+	return c.id
+}
+
+func (c testComponent) Component(model interface{}) (Component, error) {
+	if m, ok := model.(testModel); ok {
+		if loc, ok := m.overrides[c.id]; ok {
+			repo, err := CreateRepository(loc, c.repo.Ref, nil)
+			if err != nil {
+				return nil, err
+			}
+			return testComponent{id: c.id, repo: repo}, nil
+		}
+	}
+	return c, nil
+}
+
+func (c testComponent) GetRepository() Repository {
+	return c.repo
+}
+
+func (c testComponent) GetTemplates() (bool, []string) {
+	return false, nil
+}
+
+func (c testComponent) ParseModel(path string, tplC TemplateContext) (Model, error) {
+	d, err := readTestDescriptor(path)
+	if err != nil {
+		return nil, err
+	}
+	m := testModel{
+		values:       map[string]string{c.id: d.Value},
+		unreferenced: map[string]bool{},
+		overrides:    map[string]string{},
+	}
+	for id, loc := range d.Overrides {
+		m.overrides[id] = loc
+	}
+	for _, r := range d.Components {
+		if r.Unreferenced {
+			m.unreferenced[r.Id] = true
+		}
+	}
+	return m, nil
+}
+
+func (c testComponent) ParseComponents(path string, tplC TemplateContext) (Component, []Component, error) {
+	d, err := readTestDescriptor(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var parent Component
+	if d.Parent != nil {
+		parent, err = d.Parent.component()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	var comps []Component
+	for _, r := range d.Components {
+		comp, err := r.component()
+		if err != nil {
+			return nil, nil, err
+		}
+		comps = append(comps, comp)
+	}
+	return parent, comps, nil
+}
+
+func (r testDescriptorRef) component() (Component, error) {
+	repo, err := CreateRepository(r.Loc, r.Ref, nil)
+	if err != nil {
+		return nil, err
+	}
+	return testComponent{id: r.Id, repo: repo}, nil
+}
+
+func readTestDescriptor(path string) (testDescriptor, error) {
+	d := testDescriptor{}
+	b, err := ioutil.ReadFile(filepath.Join(path, "ekara.yaml"))
+	if err != nil {
+		return d, err
+	}
+	err = yaml.Unmarshal(b, &d)
+	return d, err
+}
+
+func (m testModel) IsReferenced(c Component) bool {
+	return !m.unreferenced[c.ComponentId()]
+}
+
+func (m testModel) Merge(with Model) (Model, error) {
+	res := testModel{
+		values:       map[string]string{},
+		unreferenced: map[string]bool{},
+		overrides:    map[string]string{},
+	}
+	for _, src := range []testModel{m, with.(testModel)} {
+		for k, v := range src.values {
+			res.values[k] = v
+		}
+		for k, v := range src.unreferenced {
+			res.unreferenced[k] = v
+		}
+		for k, v := range src.overrides {
+			res.overrides[k] = v
+		}
+	}
+	return res, nil
+}
+
+func (t testTemplateContext) Clone(ref ComponentRef) TemplateContext {
+	return t
+}
+
+func (t testTemplateContext) Execute(content string) (string, error) {
+	return content, nil
+}
+
+func testLogger() Logger {
+	return NewStdLogger(log.New(os.Stdout, "TEST: ", log.Ldate|log.Ltime))
+}
+
+func createTestComponentTester(t *testing.T) *ComponentTester {
+	return CreateComponentTester(TestContext{
+		T:         t,
+		Logger:    testLogger(),
+		Directory: os.TempDir(),
+	}, testTemplateContext{})
+}
+
+func TestInitWithParentAndComponents(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	parent := tester.CreateDir("parent")
+	parent.WriteCommit("ekara.yaml", "value: parent")
+	comp1 := tester.CreateDir("comp1")
+	comp1.WriteCommit("ekara.yaml", "value: comp1")
+	comp2 := tester.CreateDir("comp2")
+	comp2.WriteCommit("ekara.yaml", "value: comp2")
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", `
+parent:
+  id: parent
+  loc: `+parent.AsRepository("").Loc.String()+`
+components:
+  - id: comp1
+    loc: `+comp1.AsRepository("").Loc.String()+`
+  - id: comp2
+    loc: `+comp2.AsRepository("").Loc.String()+`
+    unreferenced: true
+value: main
+`)
+
+	err := tester.Init(testComponent{id: "main", repo: main.AsRepository("")})
+	if assert.Nil(t, err) {
+		tester.AssertComponentsExactly("main", "parent", "comp1")
+		assert.Equal(t, []string{"parent", "comp1", "main"}, tester.ComponentManager().ComponentOrder())
+		assert.Equal(t, map[string]string{"parent": "parent", "comp1": "comp1", "main": "main"}, tester.Model().(testModel).values)
+	}
+}
+
+func TestInitUpdatesTrackedBranch(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", "value: v1")
+	mainComp := testComponent{id: "main", repo: main.AsRepository("master")}
+
+	err := tester.Init(mainComp)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "v1", tester.Model().(testModel).values["main"])
+	}
+
+	// A new manager working into the same directory updates the existing clone
+	main.WriteCommit("ekara.yaml", "value: v2")
+	cm := CreateComponentManager(testLogger(), tester.compDir)
+	m, err := cm.Init(mainComp, tester.TemplateContext())
+	if assert.Nil(t, err) {
+		assert.Equal(t, "v2", m.(testModel).values["main"])
+		assert.True(t, cm.(*componentManager).fComps["main"].changed)
+	}
+
+	cm = CreateComponentManager(testLogger(), tester.compDir)
+	m, err = cm.Init(mainComp, tester.TemplateContext())
+	if assert.Nil(t, err) {
+		assert.Equal(t, "v2", m.(testModel).values["main"])
+		assert.False(t, cm.(*componentManager).fComps["main"].changed)
+	}
+}
+
+func TestInitFetchesConcurrently(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	descriptor := "components:\n"
+	var expected []string
+	for _, id := range []string{"comp1", "comp2", "comp3", "comp4", "comp5", "comp6"} {
+		r := tester.CreateDir(id)
+		r.WriteCommit("ekara.yaml", "value: "+id)
+		descriptor += "  - id: " + id + "\n    loc: " + r.AsRepository("").Loc.String() + "\n"
+		expected = append(expected, id)
+	}
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", descriptor+"value: main\n")
+	expected = append(expected, "main")
+
+	counter := &concurrencyCounter{}
+	cm := CreateComponentManager(testLogger(), tester.compDir, WithFetchWorkers(3),
+		WithScmHandler(SchemeFile, func(l Logger, u *url.URL) (ScmHandler, error) {
+			h, err := newLocalScmHandler(l, u)
+			return countingScmHandler{ScmHandler: h, counter: counter}, err
+		}))
+	m, err := cm.Init(testComponent{id: "main", repo: main.AsRepository("")}, tester.TemplateContext())
+	if assert.Nil(t, err) {
+		assert.Equal(t, expected, cm.ComponentOrder())
+		assert.Len(t, m.(testModel).values, len(expected))
+	}
+	assert.True(t, counter.peak > 1, "peak of %d concurrent fetches", counter.peak)
+	assert.True(t, counter.peak <= 3, "peak of %d concurrent fetches", counter.peak)
+}
+
+// concurrencyCounter records the peak number of operations running at once
+type concurrencyCounter struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+}
+
+// countingScmHandler is a SCM handler recording the peak number of concurrent fetches,
+// each fetch lasting long enough to overlap with the other ones
+type countingScmHandler struct {
+	ScmHandler
+	counter *concurrencyCounter
+}
+
+func (h countingScmHandler) Fetch(ctx context.Context, u *url.URL, path string, auth map[string]string) error {
+	c := h.counter
+	c.mu.Lock()
+	c.running++
+	if c.running > c.peak {
+		c.peak = c.running
+	}
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.running--
+		c.mu.Unlock()
+	}()
+	time.Sleep(100 * time.Millisecond)
+	return h.ScmHandler.Fetch(ctx, u, path, auth)
+}
+
+func TestConcurrentUse(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	comp1 := tester.CreateDir("comp1")
+	comp1.WriteCommit("ekara.yaml", "value: comp1")
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", `
+components:
+  - id: comp1
+    loc: `+comp1.AsRepository("").Loc.String()+`
+value: main
+`)
+	mainComp := testComponent{id: "main", repo: main.AsRepository("")}
+	if !assert.Nil(t, tester.Init(mainComp)) {
+		return
+	}
+	cm := tester.ComponentManager()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				u, err := cm.Use(testComponentRef("comp1"), tester.TemplateContext())
+				if assert.Nil(t, err) {
+					assert.Equal(t, "comp1", u.Id())
+					u.Release()
+				}
+				paths := cm.ContainsFile("ekara.yaml", tester.TemplateContext())
+				assert.Equal(t, 2, paths.Count())
+				paths.Release()
+				assert.True(t, cm.IsAvailable(testComponentRef("main")))
+				_, ok := cm.Resolved(testComponentRef("comp1"))
+				assert.True(t, ok)
+				assert.NotEmpty(t, cm.ComponentOrder())
+				assert.Len(t, cm.Lock().Components, 2)
+			}
+		}()
+	}
+	// Initializations can run concurrently with the other calls
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := cm.Init(mainComp, tester.TemplateContext())
+		assert.Nil(t, err)
+	}()
+	wg.Wait()
+}
+
+func TestInitContextCanceled(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", "value: main")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cm := CreateComponentManager(testLogger(), tester.compDir)
+	_, err := cm.InitContext(ctx, testComponent{id: "main", repo: main.AsRepository("")}, tester.TemplateContext())
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.False(t, cm.IsAvailable(testComponentRef("main")))
+	assert.False(t, DirExist(filepath.Join(tester.compDir, "main")))
+}
+
+// cancelingTemplateContext cancels the context when cloned more than once
+type cancelingTemplateContext struct {
+	testTemplateContext
+	clones *int
+	cancel context.CancelFunc
+}
+
+func (t cancelingTemplateContext) Clone(ref ComponentRef) TemplateContext {
+	*t.clones++
+	if *t.clones > 1 {
+		t.cancel()
+	}
+	return t
+}
+
+func TestContainsFileContextCanceled(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", "value: main")
+	mainComp := templatedTestComponent{testComponent{id: "main", repo: main.AsRepository("")}}
+	cm := tester.ComponentManager()
+	if !assert.Nil(t, tester.Init(mainComp)) {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	paths, err := cm.ContainsFileContext(ctx, "ekara.yaml", tester.TemplateContext(), mainComp, mainComp)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 2, paths.Count())
+		paths.Release()
+	}
+
+	// The search is interrupted while templating the second copy
+	tplC := cancelingTemplateContext{clones: new(int), cancel: cancel}
+	paths, err = cm.ContainsFileContext(ctx, "ekara.yaml", tplC, mainComp, mainComp)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, 0, paths.Count())
+
+	// The first templated copy has been released
+	entries, err := ioutil.ReadDir(tester.compDir)
+	if assert.Nil(t, err) {
+		for _, e := range entries {
+			assert.False(t, isTemplatedCopy(e.Name()), e.Name())
+		}
+	}
+
+	// Nothing is searched once the context is done
+	paths, err = cm.ContainsFileContext(ctx, "ekara.yaml", tester.TemplateContext())
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, 0, paths.Count())
+}
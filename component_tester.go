@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -24,7 +23,7 @@ type (
 	//ComponentTester is an helper user to run unit tests based on local GIT repositories
 	ComponentTester struct {
 		t        *testing.T
-		logger   *log.Logger
+		logger   Logger
 		rootDir  string
 		fixDir   string
 		compDir  string
@@ -38,7 +37,7 @@ type (
 
 	TestContext struct {
 		T              *testing.T
-		Logger         *log.Logger
+		Logger         Logger
 		Directory      string
 		DescriptorName string
 	}
@@ -70,7 +69,7 @@ func CreateComponentTester(ctx TestContext, tplC TemplateContext) *ComponentTest
 
 //Clean deletes all the content created locally during a test
 func (t *ComponentTester) Clean() {
-	t.logger.Printf("Cleaning up test directory %s\n", t.rootDir)
+	t.logger.Debug("cleaning up test directory", PathField(t.rootDir))
 	os.RemoveAll(t.rootDir)
 }
 
@@ -0,0 +1,305 @@
+package componentizer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	//DefaultEnvCredentialPrefix is the prefix of the environment variables read by
+	// the EnvCredentialProvider if none is specified
+	DefaultEnvCredentialPrefix = "COMPONENTIZER_AUTH"
+
+	//gitCredentialTimeout limits the time spent by the git credential helpers, which
+	// may wait for an interaction
+	gitCredentialTimeout = 30 * time.Second
+)
+
+type (
+	//CredentialProvider provides the authentication parameters used to access a
+	// repository which doesn't hold any authentication by itself.
+	//
+	//The returned parameters must follow the format of Repository.Authentication.
+	CredentialProvider interface {
+		//Credentials returns the authentication parameters for the location, or
+		// nil if the provider has no credentials for it.
+		Credentials(u *url.URL) (map[string]string, error)
+	}
+
+	//ContextCredentialProvider is implemented by the credential providers whose
+	// lookup can be interrupted, the fetch context being then used.
+	ContextCredentialProvider interface {
+		CredentialProvider
+		//CredentialsContext is like Credentials but stops as soon as possible once
+		// the context is done
+		CredentialsContext(ctx context.Context, u *url.URL) (map[string]string, error)
+	}
+
+	//CredentialProviderFunc is an adapter allowing to use a function as CredentialProvider
+	CredentialProviderFunc func(u *url.URL) (map[string]string, error)
+
+	//NetrcCredentialProvider provides credentials from a netrc file
+	NetrcCredentialProvider struct {
+		// Path of the netrc file, if not specified $NETRC or ~/.netrc is used
+		Path string
+	}
+
+	//EnvCredentialProvider provides credentials from environment variables named
+	// after the repository host, as "COMPONENTIZER_AUTH_GITHUB_COM_TOKEN".
+	//
+	//The supported variables suffixes are METHOD, USER, PASSWORD, TOKEN, KEY and
+	// PASSPHRASE; when METHOD is not specified it is deduced from the other variables.
+	EnvCredentialProvider struct {
+		// Prefix of the variables, if not specified DefaultEnvCredentialPrefix is used
+		Prefix string
+	}
+
+	//GitCredentialProvider provides credentials from the git credential helpers
+	// configured on the machine, using "git credential fill"
+	GitCredentialProvider struct{}
+)
+
+//Credentials implements CredentialProvider
+func (f CredentialProviderFunc) Credentials(u *url.URL) (map[string]string, error) {
+	return f(u)
+}
+
+//resolveCredentials returns the given authentication if any, otherwise the first
+// credentials found by the providers
+func resolveCredentials(ctx context.Context, u *url.URL, auth map[string]string, providers []CredentialProvider) (map[string]string, error) {
+	if len(auth) > 0 {
+		return auth, nil
+	}
+	for _, p := range providers {
+		var c map[string]string
+		var err error
+		if cp, ok := p.(ContextCredentialProvider); ok {
+			c, err = cp.CredentialsContext(ctx, u)
+		} else {
+			c, err = p.Credentials(u)
+		}
+		if err == nil {
+			err = canceled(ctx, "credentials lookup")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to get credentials for %s: %w", u.Host, err)
+		}
+		if c != nil {
+			return c, nil
+		}
+	}
+	return auth, nil
+}
+
+//passwordMethod returns the authentication method to use with a user and a password on the location
+func passwordMethod(u *url.URL) string {
+	if u.Scheme == SchemeSsh {
+		return "password"
+	}
+	return "basic"
+}
+
+//Credentials implements CredentialProvider
+func (p NetrcCredentialProvider) Credentials(u *url.URL) (map[string]string, error) {
+	path := p.Path
+	if path == "" {
+		path = os.Getenv("NETRC")
+	}
+	if path == "" {
+		usr, err := user.Current()
+		if err != nil {
+			return nil, nil
+		}
+		path = filepath.Join(usr.HomeDir, ".netrc")
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	login, password, found := parseNetrc(string(content), u.Hostname())
+	if !found {
+		return nil, nil
+	}
+	return map[string]string{
+		"method":   passwordMethod(u),
+		"user":     login,
+		"password": password,
+	}, nil
+}
+
+//parseNetrc returns the login and password of the machine, the default entry
+// being used if the machine is not explicitly listed
+func parseNetrc(content string, machine string) (string, string, bool) {
+	type entry struct {
+		login, password string
+	}
+	var current, def *entry
+	var matched *entry
+	inMacro := false
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			// A macro definition ends with an empty line
+			if strings.TrimSpace(line) == "" {
+				inMacro = false
+			}
+			continue
+		}
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			value := ""
+			if i+1 < len(fields) {
+				value = fields[i+1]
+			}
+			switch fields[i] {
+			case "machine":
+				current = &entry{}
+				if value == machine && matched == nil {
+					matched = current
+				}
+				i++
+			case "default":
+				current = &entry{}
+				def = current
+			case "login":
+				if current != nil {
+					current.login = value
+				}
+				i++
+			case "password":
+				if current != nil {
+					current.password = value
+				}
+				i++
+			case "account":
+				i++
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+
+	if matched != nil {
+		return matched.login, matched.password, true
+	}
+	if def != nil {
+		return def.login, def.password, true
+	}
+	return "", "", false
+}
+
+//Credentials implements CredentialProvider
+func (p EnvCredentialProvider) Credentials(u *url.URL) (map[string]string, error) {
+	prefix := p.Prefix
+	if prefix == "" {
+		prefix = DefaultEnvCredentialPrefix
+	}
+	prefix = prefix + "_" + envHostName(u.Hostname()) + "_"
+
+	auth := map[string]string{}
+	for _, k := range []string{"method", "user", "password", "token", "key", "passphrase"} {
+		if v, ok := os.LookupEnv(prefix + strings.ToUpper(k)); ok {
+			auth[k] = v
+		}
+	}
+	if len(auth) == 0 {
+		return nil, nil
+	}
+	if _, ok := auth["method"]; !ok {
+		switch {
+		case auth["token"] != "":
+			auth["method"] = "token"
+		case auth["key"] != "":
+			auth["method"] = "key"
+		default:
+			auth["method"] = passwordMethod(u)
+		}
+	}
+	return auth, nil
+}
+
+//envHostName returns the host name usable into an environment variable name
+func envHostName(host string) string {
+	return strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, host)
+}
+
+//Credentials implements CredentialProvider
+func (p GitCredentialProvider) Credentials(u *url.URL) (map[string]string, error) {
+	return p.CredentialsContext(context.Background(), u)
+}
+
+//CredentialsContext implements ContextCredentialProvider, the helpers being
+// interrupted after gitCredentialTimeout
+func (p GitCredentialProvider) CredentialsContext(ctx context.Context, u *url.URL) (map[string]string, error) {
+	if u.Scheme != SchemeHttp && u.Scheme != SchemeHttps {
+		// Git credential helpers only deal with http credentials
+		return nil, nil
+	}
+
+	var in bytes.Buffer
+	in.WriteString("protocol=" + u.Scheme + "\n")
+	in.WriteString("host=" + u.Host + "\n")
+	if path := strings.TrimPrefix(u.Path, "/"); path != "" {
+		in.WriteString("path=" + path + "\n")
+	}
+	if u.User != nil {
+		in.WriteString("username=" + u.User.Username() + "\n")
+	}
+	in.WriteString("\n")
+
+	ctx, cancel := context.WithTimeout(ctx, gitCredentialTimeout)
+	defer cancel()
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", "credential", "fill")
+	cmd.Stdin = &in
+	cmd.Stdout = &out
+	// Never prompt, credentials must come from the helpers
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=true")
+	if err := cmd.Run(); err != nil {
+		// No helper has been able to provide credentials
+		return nil, nil
+	}
+
+	auth := map[string]string{}
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "username":
+			auth["user"] = kv[1]
+		case "password":
+			auth["password"] = kv[1]
+		}
+	}
+	if auth["password"] == "" {
+		return nil, nil
+	}
+	auth["method"] = "basic"
+	return auth, nil
+}
@@ -0,0 +1,137 @@
+package componentizer
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetrcCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "componentizer_netrc")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, ".netrc")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`machine github.com
+	login john
+	password secret
+macdef init
+machine fake.com login fake password fake
+
+default login anonymous password guest
+`), 0600))
+
+	p := NetrcCredentialProvider{Path: path}
+	u, _ := url.Parse("https://github.com/ekara-platform/repo1")
+	auth, err := p.Credentials(u)
+	if assert.Nil(t, err) {
+		assert.Equal(t, map[string]string{"method": "basic", "user": "john", "password": "secret"}, auth)
+	}
+
+	u, _ = url.Parse("ssh://fake.com/ekara-platform/repo1")
+	auth, err = p.Credentials(u)
+	if assert.Nil(t, err) {
+		assert.Equal(t, map[string]string{"method": "password", "user": "anonymous", "password": "guest"}, auth)
+	}
+}
+
+func TestEnvCredentials(t *testing.T) {
+	os.Setenv("TEST_AUTH_GIT_EXAMPLE_COM_TOKEN", "abc")
+	defer os.Unsetenv("TEST_AUTH_GIT_EXAMPLE_COM_TOKEN")
+
+	p := EnvCredentialProvider{Prefix: "TEST_AUTH"}
+	u, _ := url.Parse("https://git.example.com/ekara-platform/repo1")
+	auth, err := p.Credentials(u)
+	if assert.Nil(t, err) {
+		assert.Equal(t, map[string]string{"method": "token", "token": "abc"}, auth)
+	}
+
+	u, _ = url.Parse("https://github.com/ekara-platform/repo1")
+	auth, err = p.Credentials(u)
+	assert.Nil(t, err)
+	assert.Nil(t, auth)
+}
+
+func TestResolveCredentials(t *testing.T) {
+	u, _ := url.Parse("https://github.com/ekara-platform/repo1")
+	empty := CredentialProviderFunc(func(u *url.URL) (map[string]string, error) {
+		return nil, nil
+	})
+	provided := CredentialProviderFunc(func(u *url.URL) (map[string]string, error) {
+		return map[string]string{"method": "token", "token": "provided"}, nil
+	})
+
+	auth, err := resolveCredentials(context.Background(), u, map[string]string{"method": "token", "token": "inline"}, []CredentialProvider{provided})
+	assert.Nil(t, err)
+	assert.Equal(t, "inline", auth["token"])
+
+	auth, err = resolveCredentials(context.Background(), u, map[string]string{}, []CredentialProvider{empty, provided})
+	assert.Nil(t, err)
+	assert.Equal(t, "provided", auth["token"])
+}
+
+func TestCredentialProvidersSkippedForLocalOrOffline(t *testing.T) {
+	calls := 0
+	counting := CredentialProviderFunc(func(u *url.URL) (map[string]string, error) {
+		calls++
+		return nil, nil
+	})
+	s := fetchSettings{credentials: []CredentialProvider{counting}}
+	for _, loc := range []string{"file:///tmp/repo", "https://github.com/ekara-platform/repo1"} {
+		repo, err := CreateRepository(loc, "", nil)
+		assert.Nil(t, err)
+		_, _, _, err = createScmHandler(context.Background(), testLogger(), testComponent{id: "comp", repo: repo}, s)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 1, calls)
+
+	s.offline = true
+	repo, err := CreateRepository("https://github.com/ekara-platform/repo1", "", nil)
+	assert.Nil(t, err)
+	_, _, _, err = createScmHandler(context.Background(), testLogger(), testComponent{id: "comp", repo: repo}, s)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestGitCredentialsCanceled(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	dir, err := ioutil.TempDir("", "componentizer_git_credentials")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// A helper waiting for an interaction, configured into a home directory
+	// rather than through GIT_CONFIG_GLOBAL which requires git 2.32
+	config := filepath.Join(dir, ".gitconfig")
+	assert.Nil(t, ioutil.WriteFile(config, []byte("[credential]\n\thelper = \"!f() { sleep 10; }; f\"\n"), 0644))
+	env := map[string]string{"HOME": dir, "XDG_CONFIG_HOME": filepath.Join(dir, ".config"), "GIT_CONFIG_NOSYSTEM": "1"}
+	for k, v := range env {
+		previous, ok := os.LookupEnv(k)
+		os.Setenv(k, v)
+		if ok {
+			defer os.Setenv(k, previous)
+		} else {
+			defer os.Unsetenv(k)
+		}
+	}
+	if previous, ok := os.LookupEnv("GIT_CONFIG_GLOBAL"); ok {
+		os.Unsetenv("GIT_CONFIG_GLOBAL")
+		defer os.Setenv("GIT_CONFIG_GLOBAL", previous)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	u, _ := url.Parse("https://github.com/ekara-platform/repo1")
+	start := time.Now()
+	_, err = resolveCredentials(ctx, u, nil, []CredentialProvider{GitCredentialProvider{}})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
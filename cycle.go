@@ -0,0 +1,69 @@
+package componentizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+type (
+	//CycleError is returned when the parent chain of a component loops back on a
+	// component already present into the chain, by identifier or by repository
+	CycleError struct {
+		// Chain holds the parent chain, from the main component to the parent
+		// closing the cycle
+		Chain []ChainedComponent
+	}
+
+	//ParentDepthError is returned when the parent chain of a component is deeper
+	// than the maximum allowed
+	ParentDepthError struct {
+		// MaxDepth is the maximum number of parents allowed above the main component
+		MaxDepth int
+		// Chain holds the parent chain, from the main component to the first parent
+		// exceeding the maximum depth
+		Chain []ChainedComponent
+	}
+
+	//ChainedComponent is a component of a parent chain
+	ChainedComponent struct {
+		Id         string
+		Repository Repository
+	}
+)
+
+func (e *CycleError) Error() string {
+	return "cycle detected in the parent chain: " + chainString(e.Chain)
+}
+
+func (e *ParentDepthError) Error() string {
+	return fmt.Sprintf("parent chain deeper than %d: %s", e.MaxDepth, chainString(e.Chain))
+}
+
+func chainString(chain []ChainedComponent) string {
+	links := make([]string, 0, len(chain))
+	for _, c := range chain {
+		links = append(links, c.Id+" ("+c.Repository.String()+")")
+	}
+	return strings.Join(links, " -> ")
+}
+
+//checkParent verifies that the parent can be appended to the chain without looping
+// back nor exceeding the maximum depth, the chain starting with the main component
+func (cm *componentManager) checkParent(chain []ChainedComponent, parent Component) error {
+	link := ChainedComponent{Id: parent.ComponentId(), Repository: parent.GetRepository()}
+	extended := append(append([]ChainedComponent{}, chain...), link)
+	for _, c := range chain {
+		if c.Id == link.Id || sameRepository(c.Repository, link.Repository) {
+			return &CycleError{Chain: extended}
+		}
+	}
+	if cm.maxParentDepth > 0 && len(chain) > cm.maxParentDepth {
+		return &ParentDepthError{MaxDepth: cm.maxParentDepth, Chain: extended}
+	}
+	return nil
+}
+
+//sameRepository returns true if both repositories target the same reference at the same location
+func sameRepository(a Repository, b Repository) bool {
+	return a.Loc != nil && b.Loc != nil && a.Loc.String() == b.Loc.String() && a.Ref == b.Ref
+}
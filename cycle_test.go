@@ -0,0 +1,91 @@
+package componentizer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitParentCycle(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	main := tester.CreateDir("main")
+	parent := tester.CreateDir("parent")
+	parent.WriteCommit("ekara.yaml", `
+parent:
+  id: main
+  loc: `+main.AsRepository("").Loc.String()+`
+value: parent
+`)
+	main.WriteCommit("ekara.yaml", `
+parent:
+  id: parent
+  loc: `+parent.AsRepository("").Loc.String()+`
+value: main
+`)
+
+	err := tester.Init(testComponent{id: "main", repo: main.AsRepository("")})
+	cycleErr := &CycleError{}
+	if assert.True(t, errors.As(err, &cycleErr)) && assert.Len(t, cycleErr.Chain, 3) {
+		assert.Equal(t, "main", cycleErr.Chain[0].Id)
+		assert.Equal(t, "parent", cycleErr.Chain[1].Id)
+		assert.Equal(t, "main", cycleErr.Chain[2].Id)
+		assert.Contains(t, err.Error(), "main ("+main.AsRepository("").String()+") -> parent")
+	}
+}
+
+func TestInitParentCycleByRepository(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", `
+parent:
+  id: other
+  loc: `+main.AsRepository("").Loc.String()+`
+value: main
+`)
+
+	err := tester.Init(testComponent{id: "main", repo: main.AsRepository("")})
+	cycleErr := &CycleError{}
+	if assert.True(t, errors.As(err, &cycleErr)) && assert.Len(t, cycleErr.Chain, 2) {
+		assert.Equal(t, "other", cycleErr.Chain[1].Id)
+	}
+}
+
+func TestInitMaxParentDepth(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	grandParent := tester.CreateDir("grandparent")
+	grandParent.WriteCommit("ekara.yaml", "value: grandparent")
+	parent := tester.CreateDir("parent")
+	parent.WriteCommit("ekara.yaml", `
+parent:
+  id: grandparent
+  loc: `+grandParent.AsRepository("").Loc.String()+`
+value: parent
+`)
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", `
+parent:
+  id: parent
+  loc: `+parent.AsRepository("").Loc.String()+`
+value: main
+`)
+	mainComp := testComponent{id: "main", repo: main.AsRepository("")}
+
+	cm := CreateComponentManager(testLogger(), tester.compDir, WithMaxParentDepth(1))
+	_, err := cm.Init(mainComp, tester.TemplateContext())
+	depthErr := &ParentDepthError{}
+	if assert.True(t, errors.As(err, &depthErr)) && assert.Len(t, depthErr.Chain, 3) {
+		assert.Equal(t, 1, depthErr.MaxDepth)
+		assert.Equal(t, "grandparent", depthErr.Chain[2].Id)
+	}
+
+	cm = CreateComponentManager(testLogger(), tester.compDir, WithMaxParentDepth(2))
+	_, err = cm.Init(mainComp, tester.TemplateContext())
+	assert.Nil(t, err)
+}
@@ -0,0 +1,172 @@
+package componentizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+type (
+	//ResolutionDiff describes how two resolutions of a main component differ
+	ResolutionDiff struct {
+		// Added holds the components only part of the second resolution, in its parsing order
+		Added []FetchResult
+		// Removed holds the components only part of the first resolution, in its parsing order
+		Removed []FetchResult
+		// Repointed holds the components part of both resolutions but fetched from
+		// another location, reference or revision, in the parsing order of the second one
+		Repointed []RepointedComponent
+		// ModelChanges holds the changes between the two merged models, nil if the
+		// models don't implement Diffable
+		ModelChanges []ModelChange
+	}
+
+	//RepointedComponent is a component resolved differently by two resolutions
+	RepointedComponent struct {
+		// Id is the component identifier
+		Id string
+		// From is how the component has been resolved by the first resolution
+		From FetchResult
+		// To is how the component has been resolved by the second resolution
+		To FetchResult
+	}
+
+	//resolution is the outcome of a resolution made into a temporary directory
+	resolution struct {
+		model   Model
+		results []FetchResult
+	}
+)
+
+//Changed returns true if the resolutions differ
+func (d ResolutionDiff) Changed() bool {
+	return len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Repointed) > 0 || len(d.ModelChanges) > 0
+}
+
+func (d ResolutionDiff) String() string {
+	var b strings.Builder
+	for _, r := range d.Added {
+		fmt.Fprintf(&b, "+ %s %s\n", r.Id, resultString(r))
+	}
+	for _, r := range d.Removed {
+		fmt.Fprintf(&b, "- %s %s\n", r.Id, resultString(r))
+	}
+	for _, r := range d.Repointed {
+		fmt.Fprintf(&b, "~ %s %s -> %s\n", r.Id, resultString(r.From), resultString(r.To))
+	}
+	for _, c := range d.ModelChanges {
+		switch {
+		case c.From == nil:
+			fmt.Fprintf(&b, "+ %s: %v\n", c.Path, c.To)
+		case c.To == nil:
+			fmt.Fprintf(&b, "- %s: %v\n", c.Path, c.From)
+		default:
+			fmt.Fprintf(&b, "~ %s: %v -> %v\n", c.Path, c.From, c.To)
+		}
+	}
+	return b.String()
+}
+
+func resultString(r FetchResult) string {
+	s := fmt.Sprintf("%s@%s", r.Location, r.Ref)
+	if r.Revision != "" {
+		s += " (" + r.Revision + ")"
+	}
+	return s
+}
+
+func (cm *componentManager) Diff(main Component, tplC TemplateContext, from []ManagerOption, to []ManagerOption) (ResolutionDiff, error) {
+	return cm.DiffContext(context.Background(), main, tplC, from, to)
+}
+
+func (cm *componentManager) DiffContext(ctx context.Context, main Component, tplC TemplateContext, from []ManagerOption, to []ManagerOption) (ResolutionDiff, error) {
+	if cm.offline {
+		return ResolutionDiff{}, errors.New("a diff can't be computed offline")
+	}
+	fromRes, err := cm.resolve(ctx, main, tplC, from)
+	if err != nil {
+		return ResolutionDiff{}, fmt.Errorf("unable to run the first resolution: %w", err)
+	}
+	toRes, err := cm.resolve(ctx, main, tplC, to)
+	if err != nil {
+		return ResolutionDiff{}, fmt.Errorf("unable to run the second resolution: %w", err)
+	}
+
+	d := ResolutionDiff{}
+	fromResults := map[string]FetchResult{}
+	for _, r := range fromRes.results {
+		fromResults[r.Id] = r
+	}
+	toResults := map[string]FetchResult{}
+	for _, r := range toRes.results {
+		toResults[r.Id] = r
+		fr, ok := fromResults[r.Id]
+		if !ok {
+			d.Added = append(d.Added, r)
+		} else if repointed(fr, r) {
+			d.Repointed = append(d.Repointed, RepointedComponent{Id: r.Id, From: fr, To: r})
+		}
+	}
+	for _, r := range fromRes.results {
+		if _, ok := toResults[r.Id]; !ok {
+			d.Removed = append(d.Removed, r)
+		}
+	}
+
+	if diffable, ok := fromRes.model.(Diffable); ok {
+		d.ModelChanges, err = diffable.Diff(toRes.model)
+		if err != nil {
+			return d, err
+		}
+	}
+	return d, nil
+}
+
+//repointed returns true if the component has been resolved from another location or
+// reference, or switched to another revision. The resolved references are only compared
+// when the revisions are unknown, a lock switching the components to their revisions.
+func repointed(from FetchResult, to FetchResult) bool {
+	if from.Location.String() != to.Location.String() || from.Repository.Ref != to.Repository.Ref {
+		return true
+	}
+	if from.Revision != "" && to.Revision != "" {
+		return from.Revision != to.Revision
+	}
+	return from.Ref != to.Ref
+}
+
+//resolve initializes a component manager derived from this one, customized by the
+// options, into a temporary directory which is removed once done. The paths of the
+// results are then cleared.
+func (cm *componentManager) resolve(ctx context.Context, main Component, tplC TemplateContext, opts []ManagerOption) (resolution, error) {
+	dir, err := ioutil.TempDir("", "componentizer_diff")
+	if err != nil {
+		return resolution{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	rm := cm.derive(dir, opts...)
+	model, err := rm.InitContext(ctx, main, tplC)
+	if err != nil {
+		return resolution{}, err
+	}
+	res := resolution{model: model}
+	seen := map[string]bool{}
+	for _, id := range rm.ComponentOrder() {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		fComp, ok := rm.isComponentFetched(id)
+		if !ok {
+			continue
+		}
+		r := fComp.result()
+		r.Path = ""
+		res.results = append(res.results, r)
+	}
+	return res, nil
+}
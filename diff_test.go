@@ -0,0 +1,98 @@
+package componentizer
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func (m testModel) Diff(to Model) ([]ModelChange, error) {
+	other := to.(testModel)
+	var changes []ModelChange
+	for k, v := range m.values {
+		if ov, ok := other.values[k]; !ok {
+			changes = append(changes, ModelChange{Path: k, From: v})
+		} else if ov != v {
+			changes = append(changes, ModelChange{Path: k, From: v, To: ov})
+		}
+	}
+	for k, v := range other.values {
+		if _, ok := m.values[k]; !ok {
+			changes = append(changes, ModelChange{Path: k, To: v})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+func TestDiff(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	comp1 := tester.CreateDir("comp1")
+	comp1.WriteCommit("ekara.yaml", "value: v1")
+	comp2 := tester.CreateDir("comp2")
+	comp2.WriteCommit("ekara.yaml", "value: comp2")
+	comp3 := tester.CreateDir("comp3")
+	comp3.WriteCommit("ekara.yaml", "value: comp3")
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", `
+components:
+  - id: comp1
+    loc: `+comp1.AsRepository("").Loc.String()+`
+  - id: comp2
+    loc: `+comp2.AsRepository("").Loc.String()+`
+value: main
+`)
+	mainComp := testComponent{id: "main", repo: main.AsRepository("")}
+	cm := tester.ComponentManager()
+	if !assert.Nil(t, tester.Init(mainComp)) {
+		return
+	}
+	lock := cm.Lock()
+
+	// Identical resolutions
+	d, err := cm.Diff(mainComp, tester.TemplateContext(), []ManagerOption{WithLockfile(lock)}, nil)
+	if assert.Nil(t, err) {
+		assert.False(t, d.Changed(), d.String())
+	}
+
+	// Upgrade comp1, replace comp2 by comp3
+	comp1.WriteCommit("ekara.yaml", "value: v2")
+	main.WriteCommit("ekara.yaml", `
+components:
+  - id: comp1
+    loc: `+comp1.AsRepository("").Loc.String()+`
+  - id: comp3
+    loc: `+comp3.AsRepository("").Loc.String()+`
+value: main
+`)
+	d, err = cm.Diff(mainComp, tester.TemplateContext(), []ManagerOption{WithLockfile(lock)}, []ManagerOption{WithoutLockfile()})
+	if !assert.Nil(t, err) {
+		return
+	}
+	if assert.Len(t, d.Added, 1) {
+		assert.Equal(t, "comp3", d.Added[0].Id)
+		assert.Empty(t, d.Added[0].Path)
+	}
+	if assert.Len(t, d.Removed, 1) {
+		assert.Equal(t, "comp2", d.Removed[0].Id)
+	}
+	if assert.Len(t, d.Repointed, 2) {
+		assert.Equal(t, "comp1", d.Repointed[0].Id)
+		assert.Equal(t, "main", d.Repointed[1].Id)
+		assert.Equal(t, lock.Components[0].Revision, d.Repointed[0].From.Revision)
+		assert.NotEqual(t, d.Repointed[0].From.Revision, d.Repointed[0].To.Revision)
+	}
+	assert.Equal(t, []ModelChange{
+		{Path: "comp1", From: "v1", To: "v2"},
+		{Path: "comp2", From: "comp2"},
+		{Path: "comp3", To: "comp3"},
+	}, d.ModelChanges)
+
+	// The work directory is left untouched
+	assertTestFileContent(t, tester.compDir+"/comp1/ekara.yaml", "value: v1")
+}
@@ -0,0 +1,206 @@
+package componentizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+type (
+	//Explanation tells why a component is part of the last initialization
+	Explanation struct {
+		// Id is the component identifier
+		Id string
+		// Path holds the discovery path, from the main component to the explained one
+		Path []DiscoveryStep
+		// Retained is true if the component is referenced by the model and then
+		// has been parsed into the final model
+		Retained bool
+		// Discovered is the repository of the component when it has been discovered
+		Discovered Repository
+		// Repository is the repository of the component resolved from the model
+		// built from the main component and its parents, if retained
+		Repository Repository
+		// OverriddenBy is the identifier of the component whose model changed the
+		// repository of the component since its discovery, if any
+		OverriddenBy string
+	}
+
+	//DiscoveryStep is a step of a discovery path
+	DiscoveryStep struct {
+		// Id is the component identifier
+		Id string
+		// Via is the relationship through which the component has been discovered
+		// from the previous step, empty for the main component
+		Via EdgeKind
+	}
+
+	//discovery records what has been discovered during an initialization
+	discovery struct {
+		graph Graph
+		tplC  TemplateContext
+		// discovered holds the components as they have been discovered first, by id
+		discovered map[string]Component
+		// resolved holds the retained components as resolved from the model, by id
+		resolved map[string]Component
+		// overriddenBy holds the identifier of the component whose model changed the
+		// repository of a retained component, by id
+		overriddenBy map[string]string
+		// chain holds the main component and its parents in the order their models
+		// have been merged into the model used to resolve the components
+		chain []parsedComponent
+	}
+
+	//parsedComponent is the model parsed from the main component or a parent
+	parsedComponent struct {
+		id    string
+		model Model
+	}
+)
+
+func newDiscovery(main Component, tplC TemplateContext) *discovery {
+	d := &discovery{
+		tplC:         tplC,
+		discovered:   map[string]Component{main.ComponentId(): main},
+		resolved:     map[string]Component{},
+		overriddenBy: map[string]string{},
+	}
+	d.graph.addNode(main)
+	return d
+}
+
+//discover records the component discovered into the descriptor of another one
+func (d *discovery) discover(by Component, c Component, kind EdgeKind) {
+	d.graph.addEdge(by, c, kind)
+	if _, ok := d.discovered[c.ComponentId()]; !ok {
+		d.discovered[c.ComponentId()] = c
+	}
+}
+
+//retain records the component as retained, as resolved from the model, along with
+// the component whose model eventually changed its repository
+func (d *discovery) retain(c Component) error {
+	d.graph.retain(c)
+	d.resolved[c.ComponentId()] = c
+	discovered, ok := d.discovered[c.ComponentId()]
+	if !ok || sameRepository(c.GetRepository(), discovered.GetRepository()) {
+		return nil
+	}
+	by, err := d.findOverride(discovered)
+	if err != nil {
+		return err
+	}
+	if by != "" {
+		d.overriddenBy[c.ComponentId()] = by
+	}
+	return nil
+}
+
+//parsed records the model of the main component or of a parent, merged into the
+// model used to resolve the components
+func (d *discovery) parsed(id string, model Model) {
+	d.chain = append(d.chain, parsedComponent{id: id, model: model})
+}
+
+//path returns the discovery path of the component, following the relationships
+// through which the components have been discovered first
+func (d *discovery) path(id string) []DiscoveryStep {
+	if len(d.graph.Nodes) == 0 {
+		return nil
+	}
+	main := d.graph.Nodes[0].Id
+	steps := []DiscoveryStep{}
+	visited := map[string]bool{}
+	for id != main && !visited[id] {
+		visited[id] = true
+		found := false
+		for _, e := range d.graph.Edges {
+			if e.To == id {
+				steps = append([]DiscoveryStep{{Id: id, Via: e.Kind}}, steps...)
+				id = e.From
+				found = true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return append([]DiscoveryStep{{Id: main}}, steps...)
+}
+
+//findOverride returns the identifier of the component, from the main one and its parents,
+// whose model changes the repository of the discovered component
+func (d *discovery) findOverride(c Component) (string, error) {
+	var model Model
+	for _, link := range d.chain {
+		if link.model == nil {
+			continue
+		}
+		var err error
+		if model != nil {
+			model, err = model.Merge(link.model)
+			if err != nil {
+				return "", err
+			}
+		} else {
+			model = link.model
+		}
+		r, err := c.Component(model)
+		if err != nil {
+			return "", err
+		}
+		if !sameRepository(r.GetRepository(), c.GetRepository()) {
+			return link.id, nil
+		}
+	}
+	return "", nil
+}
+
+func (cm *componentManager) Explain(id string) (Explanation, error) {
+	cm.mu.RLock()
+	d := cm.discovery
+	cm.mu.RUnlock()
+	if d == nil {
+		return Explanation{}, fmt.Errorf("component %s has not been discovered, the component manager has not been initialized", id)
+	}
+
+	node, ok := d.graph.Node(id)
+	if !ok {
+		return Explanation{}, fmt.Errorf("component %s has not been discovered during the last initialization", id)
+	}
+	e := Explanation{
+		Id:         id,
+		Path:       d.path(id),
+		Retained:   node.Retained,
+		Discovered: d.discovered[id].GetRepository(),
+	}
+	resolved, ok := d.resolved[id]
+	if !ok {
+		return e, nil
+	}
+	e.Repository = resolved.GetRepository()
+	e.OverriddenBy = d.overriddenBy[id]
+	return e, nil
+}
+
+func (e Explanation) String() string {
+	steps := make([]string, 0, len(e.Path))
+	for _, s := range e.Path {
+		if s.Via != "" {
+			steps = append(steps, "-("+string(s.Via)+")-> "+s.Id)
+		} else {
+			steps = append(steps, s.Id)
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s discovered through %s", e.Id, strings.Join(steps, " "))
+	if !e.Retained {
+		b.WriteString(", not retained")
+		return b.String()
+	}
+	b.WriteString(", retained")
+	if e.OverriddenBy != "" {
+		fmt.Fprintf(&b, ", repository %s overridden by %s into %s", e.Discovered.String(), e.OverriddenBy, e.Repository.String())
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package componentizer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplain(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	comp1 := tester.CreateDir("comp1")
+	comp1.WriteCommit("ekara.yaml", "value: comp1")
+	comp1Fork := tester.CreateDir("comp1_fork")
+	comp1Fork.WriteCommit("ekara.yaml", "value: comp1 fork")
+	comp2 := tester.CreateDir("comp2")
+	comp2.WriteCommit("ekara.yaml", "value: comp2")
+	parent := tester.CreateDir("parent")
+	parent.WriteCommit("ekara.yaml", `
+overrides:
+  comp1: `+comp1Fork.AsRepository("").Loc.String()+`
+value: parent
+`)
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", `
+parent:
+  id: parent
+  loc: `+parent.AsRepository("").Loc.String()+`
+components:
+  - id: comp1
+    loc: `+comp1.AsRepository("").Loc.String()+`
+  - id: comp2
+    loc: `+comp2.AsRepository("").Loc.String()+`
+    unreferenced: true
+value: main
+`)
+
+	_, err := tester.ComponentManager().Explain("comp1")
+	assert.NotNil(t, err)
+
+	err = tester.Init(testComponent{id: "main", repo: main.AsRepository("")})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "comp1 fork", tester.Model().(testModel).values["comp1"])
+	cm := tester.ComponentManager()
+
+	e, err := cm.Explain("comp1")
+	if assert.Nil(t, err) {
+		assert.Equal(t, []DiscoveryStep{{Id: "main"}, {Id: "comp1", Via: EdgeReference}}, e.Path)
+		assert.True(t, e.Retained)
+		assert.Equal(t, comp1.AsRepository("").String(), e.Discovered.String())
+		assert.Equal(t, comp1Fork.AsRepository("").String(), e.Repository.String())
+		assert.Equal(t, "parent", e.OverriddenBy)
+		assert.Contains(t, e.String(), "comp1 discovered through main -(reference)-> comp1, retained, repository")
+	}
+
+	e, err = cm.Explain("parent")
+	if assert.Nil(t, err) {
+		assert.Equal(t, []DiscoveryStep{{Id: "main"}, {Id: "parent", Via: EdgeParent}}, e.Path)
+		assert.True(t, e.Retained)
+		assert.Empty(t, e.OverriddenBy)
+	}
+
+	e, err = cm.Explain("comp2")
+	if assert.Nil(t, err) {
+		assert.False(t, e.Retained)
+		assert.Equal(t, "comp2 discovered through main -(reference)-> comp2, not retained", e.String())
+	}
+
+	_, err = cm.Explain("unknown")
+	assert.NotNil(t, err)
+
+	// The explanation doesn't depend on the current content of the work directory
+	parent.WriteCommit("ekara.yaml", "value: parent without override")
+	changed, err := cm.Refresh(testComponent{id: "parent"})
+	if assert.Nil(t, err) {
+		assert.True(t, changed)
+	}
+	assert.Nil(t, os.RemoveAll(filepath.Join(tester.compDir, "main")))
+	e, err = cm.Explain("comp1")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "parent", e.OverriddenBy)
+	}
+}
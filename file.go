@@ -1,15 +1,48 @@
 package componentizer
 
 import (
-	"log"
+	"context"
+	"io/ioutil"
 	"net/url"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/src-d/go-git.v4"
 )
 
 //FileScmHandler Represents the scm connector allowing to fetch local repositories.
 //
 //It implements "github.com/ekara-platform/engine/component/scm.scmHandler
 type FileScmHandler struct {
-	Logger *log.Logger
+	Logger Logger
+}
+
+//workTreeScmHandler copies the working tree of a local git repository, whatever the
+// reference of the component, in order to pick up its uncommitted changes
+type workTreeScmHandler struct {
+	FileScmHandler
+}
+
+//newLocalScmHandler returns the handler corresponding to the content of a local
+// location, a plain directory is copied and an archive is extracted while anything
+// else is assumed to be a git repository
+func newLocalScmHandler(l Logger, u *url.URL) (ScmHandler, error) {
+	if isPlainDirectory(u.Path) {
+		return FileScmHandler{Logger: l}, nil
+	}
+	if isArchive(u) {
+		return ArchiveScmHandler{Logger: l}, nil
+	}
+	return GitScmHandler{Logger: l}, nil
+}
+
+//isPlainDirectory returns true if the path is an existing directory which is not a git repository
+func isPlainDirectory(path string) bool {
+	if !DirExist(path) {
+		return false
+	}
+	_, err := git.PlainOpen(path)
+	return err == git.ErrRepositoryNotExists
 }
 
 //Matches implements "github.com/ekara-platform/engine/component/scm.scmHandler
@@ -19,20 +52,51 @@ func (fileScm FileScmHandler) Matches(u *url.URL, path string) bool {
 }
 
 //Fetch implements "github.com/ekara-platform/engine/component/scm.scmHandler
-func (fileScm FileScmHandler) Fetch(u *url.URL, path string, auth map[string]string) error {
-	return copyDir(u.Path, path)
+func (fileScm FileScmHandler) Fetch(ctx context.Context, u *url.URL, path string, auth map[string]string) error {
+	fileScm.Logger.Debug("copying directory", UrlField(u.String()), PathField(path))
+	return copyDir(ctx, u.Path, path)
 }
 
 //Update implements "github.com/ekara-platform/engine/component/scm.scmHandler
-func (fileScm FileScmHandler) Update(path string, auth map[string]string) error {
+func (fileScm FileScmHandler) Update(ctx context.Context, path string, auth map[string]string) (bool, error) {
 	// Doing nothing here and it's okay because Matches returns false
 	// then the repo will be fetched/copied from scratch and never updated
-	return nil
+	return false, nil
 }
 
 //Switch implements "github.com/ekara-platform/engine/component/scm.scmHandler
-func (fileScm FileScmHandler) Switch(path string, ref string) error {
+func (fileScm FileScmHandler) Switch(ctx context.Context, path string, ref string) error {
 	// Doing nothing here and it's okay because we are dealing with
 	// physical files then there  is nothing to switch...
 	return nil
 }
+
+//Fetch implements "github.com/ekara-platform/engine/component/scm.scmHandler, the git
+// directory being left out of the copy
+func (wtScm workTreeScmHandler) Fetch(ctx context.Context, u *url.URL, path string, auth map[string]string) error {
+	wtScm.Logger.Debug("copying working tree", UrlField(u.String()), PathField(path))
+	entries, err := ioutil.ReadDir(u.Path)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(path, 0755)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		srcPath := filepath.Join(u.Path, entry.Name())
+		dstPath := filepath.Join(path, entry.Name())
+		switch {
+		case entry.Name() == git.GitDirName || entry.Mode()&os.ModeSymlink != 0:
+			continue
+		case entry.IsDir():
+			err = copyDir(ctx, srcPath, dstPath)
+		default:
+			err = copyFile(srcPath, dstPath)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
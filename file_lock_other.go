@@ -0,0 +1,33 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package componentizer
+
+import (
+	"os"
+	"time"
+)
+
+//staleLockAge is the age from which a lock file is considered as left by a process
+// which ended without releasing it
+const staleLockAge = 30 * time.Minute
+
+//tryLockFile tries to lock exclusively the file by creating it, without waiting.
+// The file being removed when the lock is released, a file older than staleLockAge
+// is considered as left by a crashed process and removed.
+func tryLockFile(path string) (func(), bool, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if os.IsExist(err) {
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(path)
+		}
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	f.Close()
+	return func() {
+		os.Remove(path)
+	}, true, nil
+}
@@ -1,27 +1,52 @@
 package componentizer
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
 	"net/url"
+	"path/filepath"
+	"sort"
 	"strings"
 
+	gossh "golang.org/x/crypto/ssh"
+	"gopkg.in/src-d/go-billy.v4/osfs"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/http"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
 
 	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
 )
 
-const defaultGitRemoteName = "origin"
+const (
+	defaultGitRemoteName = "origin"
+	defaultGitSshUser    = "git"
+	// minHashPrefixLength is the minimal length of an abbreviated commit hash, as in git
+	minHashPrefixLength = 4
+)
 
 //GitScmHandler Represents the scm connector allowing to fecth GIT repositories.
 //
 //It implements "github.com/ekara-platform/engine/component/scm.scmHandler
+//
+//If a cache directory is specified then the repositories are first mirrored into it,
+// allowing to share their data between work directories, and then cloned locally.
+// The local clones borrow the objects of the mirror through "objects/info/alternates"
+// and then depend on it, the mirrors must not be pruned.
 type GitScmHandler struct {
-	Logger *log.Logger
+	Logger Logger
+	// CacheDir is the directory holding the bare mirrors of the repositories
+	CacheDir string
+}
+
+func newGitScmHandler(l Logger, u *url.URL) (ScmHandler, error) {
+	return GitScmHandler{Logger: l}, nil
 }
 
 //Matches implements "github.com/ekara-platform/engine/component/scm.scmHandler
@@ -36,8 +61,13 @@ func (gitScm GitScmHandler) Matches(u *url.URL, path string) bool {
 		return false
 	}
 
+	source := u.String()
+	if gitScm.CacheDir != "" {
+		// The repository has been cloned from the mirror
+		source = gitScm.mirrorPath(u)
+	}
 	for _, remoteConfig := range config.Remotes {
-		if len(remoteConfig.URLs) > 0 && remoteConfig.URLs[0] == u.String() {
+		if len(remoteConfig.URLs) > 0 && remoteConfig.URLs[0] == source {
 			return true
 		}
 	}
@@ -46,14 +76,29 @@ func (gitScm GitScmHandler) Matches(u *url.URL, path string) bool {
 }
 
 //Fetch implements "github.com/ekara-platform/engine/component/scm.scmHandler
-func (gitScm GitScmHandler) Fetch(u *url.URL, path string, auth map[string]string) error {
+func (gitScm GitScmHandler) Fetch(ctx context.Context, u *url.URL, path string, auth map[string]string) error {
 	source := u.String()
+	wt := osfs.New(path)
+	dot, _ := wt.Chroot(git.GitDirName)
+	if gitScm.CacheDir != "" {
+		mirror, err := gitScm.syncMirror(ctx, u, auth)
+		if err != nil {
+			return err
+		}
+		// Clone locally from the mirror which doesn't require any authentication,
+		// the objects of the mirror being shared instead of copied
+		source, auth = mirror, nil
+		err = shareObjects(dot.Root(), mirror)
+		if err != nil {
+			return errors.New("unable to share objects of cache mirror " + mirror + ": " + err.Error())
+		}
+	}
 	options := git.CloneOptions{
 		RemoteName:   defaultGitRemoteName,
 		URL:          source,
 		SingleBranch: false,
 		Tags:         git.AllTags}
-	authMethod, e := buildAuthMethod(auth)
+	authMethod, e := buildAuthMethod(u, auth)
 	if e != nil {
 		return errors.New("error cloning git repository " + source + ": " + e.Error())
 	}
@@ -61,8 +106,9 @@ func (gitScm GitScmHandler) Fetch(u *url.URL, path string, auth map[string]strin
 		options.Auth = authMethod
 	}
 
-	gitScm.Logger.Println("cloning GIT repository " + source)
-	_, err := git.PlainClone(path, false, &options)
+	gitScm.Logger.Debug("cloning GIT repository", UrlField(source), PathField(path))
+	st := filesystem.NewStorage(dot, cache.NewObjectLRUDefault())
+	_, err := git.CloneContext(ctx, st, wt, &options)
 	if err != nil {
 		return errors.New("unable to clone git repository " + source + ": " + err.Error())
 	}
@@ -70,34 +116,248 @@ func (gitScm GitScmHandler) Fetch(u *url.URL, path string, auth map[string]strin
 }
 
 //Update implements "github.com/ekara-platform/engine/component/scm.scmHandler
-func (gitScm GitScmHandler) Update(path string, auth map[string]string) error {
+//
+//The remote branches and tags are forced to their latest remote state, a warning
+// being logged for each branch which has been force-pushed or has diverged.
+func (gitScm GitScmHandler) Update(ctx context.Context, path string, auth map[string]string) (bool, error) {
 	options := git.FetchOptions{
-		Tags: git.AllTags}
-	authMethod, e := buildAuthMethod(auth)
+		RemoteName: defaultGitRemoteName,
+		Tags:       git.AllTags,
+		Force:      true}
+
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return false, errors.New("unable to open git repository " + path + ": " + err.Error())
+	}
+	config, err := repo.Config()
+	if err != nil {
+		return false, errors.New("unable to access config of git repository " + path + ": " + err.Error())
+	}
+	if gitScm.CacheDir != "" {
+		// The repository has been cloned from the mirror which must be updated first
+		err = gitScm.updateMirror(ctx, config.Remotes[defaultGitRemoteName].URLs[0], auth)
+		if err != nil {
+			return false, err
+		}
+		auth = nil
+	}
+	remoteUrl, _ := url.Parse(config.Remotes[defaultGitRemoteName].URLs[0])
+	authMethod, e := buildAuthMethod(remoteUrl, auth)
 	if e != nil {
-		return errors.New("error updating git repository " + path + ": " + e.Error())
+		return false, errors.New("error updating git repository " + path + ": " + e.Error())
 	}
 	options.Auth = authMethod
+	before, err := remoteRefs(repo)
+	if err != nil {
+		return false, errors.New("unable to list references of git repository " + path + ": " + err.Error())
+	}
 
+	gitScm.Logger.Debug("fetching latest data", UrlField(config.Remotes[defaultGitRemoteName].URLs[0]), PathField(path))
+	err = repo.FetchContext(ctx, &options)
+	if err == git.NoErrAlreadyUpToDate {
+		gitScm.Logger.Debug("already up-to-date", PathField(path))
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.New("unable to fetch latest data for git repository " + path + ": " + err.Error())
+	}
+
+	after, err := remoteRefs(repo)
+	if err != nil {
+		return false, errors.New("unable to list references of git repository " + path + ": " + err.Error())
+	}
+	changed := len(before) != len(after)
+	for name, hash := range after {
+		old, ok := before[name]
+		if !ok {
+			changed = true
+			continue
+		}
+		if old == hash {
+			continue
+		}
+		changed = true
+		if name.IsRemote() && !isAncestor(repo, old, hash) {
+			gitScm.Logger.Warn("branch has been force-pushed or has diverged", PathField(path), RefField(name.Short()), NewField("from", old.String()), NewField("to", hash.String()))
+		}
+	}
+	return changed, nil
+}
+
+//ResolveRef implements "github.com/GroupePSA/componentizer.RefResolver
+//
+//Version ranges, as "^1.4" or "~2.0.3", are resolved into the tag with the highest
+// matching semantic version, any other reference being returned unchanged.
+func (gitScm GitScmHandler) ResolveRef(path string, ref string) (string, error) {
+	if !isVersionConstraint(ref) {
+		return ref, nil
+	}
+	constraint, err := parseConstraint(ref)
+	if err != nil {
+		return "", err
+	}
 	repo, err := git.PlainOpen(path)
 	if err != nil {
-		return errors.New("unable to open git repository " + path + ": " + err.Error())
+		return "", errors.New("unable to open git repository " + path + ": " + err.Error())
 	}
-	config, err := repo.Config()
+	tags, err := repo.Tags()
 	if err != nil {
-		return errors.New("unable to access config of git repository " + path + ": " + err.Error())
+		return "", errors.New("unable to list tags of git repository " + path + ": " + err.Error())
 	}
-	gitScm.Logger.Println("fetching latest data from " + config.Remotes[defaultGitRemoteName].URLs[0])
-	err = repo.Fetch(&options)
-	if err == git.NoErrAlreadyUpToDate {
-		gitScm.Logger.Println("already up-to-date")
+	var names []string
+	err = tags.ForEach(func(tag *plumbing.Reference) error {
+		names = append(names, tag.Name().Short())
 		return nil
+	})
+	if err != nil {
+		return "", errors.New("unable to list tags of git repository " + path + ": " + err.Error())
+	}
+	tag, ok := constraint.highestMatching(names)
+	if !ok {
+		return "", errors.New("no tag matching " + ref + " in git repository " + path)
+	}
+	gitScm.Logger.Info("version resolved", PathField(path), RefField(ref), NewField("tag", tag))
+	return tag, nil
+}
+
+//Revision implements "github.com/GroupePSA/componentizer.RevisionReader
+//
+//The revision is the hash of the commit checked out.
+func (gitScm GitScmHandler) Revision(path string) (string, error) {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return "", errors.New("unable to open git repository " + path + ": " + err.Error())
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", errors.New("unable to resolve HEAD of git repository " + path + ": " + err.Error())
+	}
+	return head.Hash().String(), nil
+}
+
+//RemoteRevision implements "github.com/GroupePSA/componentizer.RemoteInspector
+//
+//The references of the remote repository are listed, as "git ls-remote" does, and
+// the reference is resolved as Switch and ResolveRef would do. Abbreviated commit
+// hashes can't be resolved without fetching the repository.
+func (gitScm GitScmHandler) RemoteRevision(ctx context.Context, u *url.URL, ref string, auth map[string]string) (string, string, error) {
+	if err := canceled(ctx, "listing of "+u.String()); err != nil {
+		return "", "", err
+	}
+	authMethod, err := buildAuthMethod(u, auth)
+	if err != nil {
+		return "", "", errors.New("error listing git repository " + u.String() + ": " + err.Error())
+	}
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: defaultGitRemoteName,
+		URLs: []string{u.String()},
+	})
+	gitScm.Logger.Debug("listing remote references", UrlField(u.String()), RefField(ref))
+	list, err := listContext(ctx, remote, &git.ListOptions{Auth: authMethod})
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", "", canceled(ctx, "listing of "+u.String())
+		}
+		return "", "", errors.New("unable to list references of git repository " + u.String() + ": " + err.Error())
+	}
+	refs := map[plumbing.ReferenceName]*plumbing.Reference{}
+	var tags []string
+	for _, r := range list {
+		refs[r.Name()] = r
+		if r.Name().IsTag() {
+			tags = append(tags, r.Name().Short())
+		}
+	}
+	if isVersionConstraint(ref) {
+		constraint, err := parseConstraint(ref)
+		if err != nil {
+			return "", "", err
+		}
+		tag, ok := constraint.highestMatching(tags)
+		if !ok {
+			return "", "", errors.New("no tag matching " + ref + " in git repository " + u.String())
+		}
+		ref = tag
+	}
+
+	var candidates []plumbing.ReferenceName
+	switch {
+	case ref == "":
+		candidates = []plumbing.ReferenceName{plumbing.HEAD}
+	case strings.HasPrefix(ref, "refs/"):
+		candidates = []plumbing.ReferenceName{plumbing.ReferenceName(ref)}
+	case isFullHash(ref):
+		return ref, strings.ToLower(ref), nil
+	default:
+		candidates = []plumbing.ReferenceName{plumbing.NewTagReferenceName(ref), plumbing.NewBranchReferenceName(ref)}
+	}
+	for _, name := range candidates {
+		r, ok := refs[name]
+		// Follow symbolic references, as HEAD
+		for i := 0; ok && r.Type() == plumbing.SymbolicReference && i < 10; i++ {
+			r, ok = refs[r.Target()]
+		}
+		if ok && r.Type() == plumbing.HashReference {
+			return ref, r.Hash().String(), nil
+		}
+	}
+	if isHashPrefix(ref) {
+		return ref, "", nil
+	}
+	return "", "", errors.New("no tag or branch named " + ref + " in git repository " + u.String())
+}
+
+//listContext lists the references of the remote, as long as the context is not done; the
+// listing can't be interrupted then it keeps running in background until its end
+func listContext(ctx context.Context, remote *git.Remote, o *git.ListOptions) ([]*plumbing.Reference, error) {
+	type result struct {
+		refs []*plumbing.Reference
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		refs, err := remote.List(o)
+		done <- result{refs: refs, err: err}
+	}()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-done:
+		return r.refs, r.err
+	}
+}
+
+//LocalRevision implements "github.com/GroupePSA/componentizer.RemoteInspector
+//
+//Annotated tags are peeled to the commit they point to.
+func (gitScm GitScmHandler) LocalRevision(path string, revision string) (string, bool) {
+	if !isFullHash(revision) {
+		return "", false
+	}
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return "", false
+	}
+	hash := plumbing.NewHash(revision)
+	if tag, err := repo.TagObject(hash); err == nil {
+		c, err := tag.Commit()
+		if err != nil {
+			return "", false
+		}
+		return c.Hash.String(), true
 	}
-	return errors.New("unable to fetch latest data for git repository " + path + ": " + err.Error())
+	if _, err := repo.CommitObject(hash); err != nil {
+		return "", false
+	}
+	return hash.String(), true
 }
 
 //Switch implements "github.com/ekara-platform/engine/component/scm.scmHandler
-func (gitScm GitScmHandler) Switch(path string, ref string) error {
+func (gitScm GitScmHandler) Switch(ctx context.Context, path string, ref string) error {
+	// The checkout is local and can't be interrupted
+	if err := canceled(ctx, "checkout of "+ref); err != nil {
+		return err
+	}
 	repo, err := git.PlainOpen(path)
 	if err != nil {
 		return errors.New("unable to open git repository " + path + ": " + err.Error())
@@ -106,20 +366,30 @@ func (gitScm GitScmHandler) Switch(path string, ref string) error {
 	if err != nil {
 		return errors.New("unable to access work tree of git repository " + path + ": " + err.Error())
 	}
-	if ref != "" {
-		// Only do a checkout if a ref is specified
+	if ref == "" {
+		// Without ref the current branch is moved to the latest remote commit
+		err = resetToRemote(repo, tree)
+	} else {
 		if strings.HasPrefix(ref, "refs/") {
 			// Raw refs are checked out as-is
-			gitScm.Logger.Println("checking out " + ref)
+			gitScm.Logger.Debug("checking out reference", PathField(path), RefField(ref))
 			err = checkout(tree, ref)
+		} else if isFullHash(ref) {
+			// Full commit hashes can't be anything else
+			gitScm.Logger.Debug("checking out commit", PathField(path), RefField(ref))
+			err = checkoutCommit(repo, tree, ref)
 		} else {
-			// Otherwise try tag first then branch
-			gitScm.Logger.Println("checking out tag " + ref)
+			// Otherwise try tag first then branch and finally an abbreviated commit hash
+			gitScm.Logger.Debug("checking out tag", PathField(path), RefField(ref))
 			err = checkout(tree, fmt.Sprintf("refs/tags/%s", ref))
 			if err != nil {
-				gitScm.Logger.Println("no tag named " + ref + " checking out branch instead")
+				gitScm.Logger.Debug("no tag found, checking out branch instead", PathField(path), RefField(ref))
 				err = checkout(tree, fmt.Sprintf("refs/remotes/%s/%s", defaultGitRemoteName, ref))
 			}
+			if err != nil && isHashPrefix(ref) {
+				gitScm.Logger.Debug("no branch found, checking out commit instead", PathField(path), RefField(ref))
+				err = checkoutCommit(repo, tree, ref)
+			}
 		}
 	}
 	if err != nil {
@@ -128,24 +398,246 @@ func (gitScm GitScmHandler) Switch(path string, ref string) error {
 	return nil
 }
 
+//resetToRemote moves the current branch to the commit of its remote counterpart, if any
+func resetToRemote(repo *git.Repository, tree *git.Worktree) error {
+	head, err := repo.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return err
+	}
+	branch := head.Target()
+	if head.Type() != plumbing.SymbolicReference || !branch.IsBranch() {
+		// Detached head, go back to the branch created by the clone if any
+		branch, err = clonedBranch(repo)
+		if err != nil || branch == "" {
+			return err
+		}
+		err = tree.Checkout(&git.CheckoutOptions{
+			Branch: branch,
+			Force:  true})
+		if err != nil {
+			return err
+		}
+	}
+	remote, err := repo.Reference(plumbing.NewRemoteReferenceName(defaultGitRemoteName, branch.Short()), true)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return tree.Reset(&git.ResetOptions{
+		Commit: remote.Hash(),
+		Mode:   git.HardReset})
+}
+
+//clonedBranch returns the local branch of the repository, which is the default branch
+// created by the clone, or nothing if there isn't exactly one local branch
+func clonedBranch(repo *git.Repository) (plumbing.ReferenceName, error) {
+	branches, err := repo.Branches()
+	if err != nil {
+		return "", err
+	}
+	var names []plumbing.ReferenceName
+	err = branches.ForEach(func(ref *plumbing.Reference) error {
+		names = append(names, ref.Name())
+		return nil
+	})
+	if err != nil || len(names) != 1 {
+		return "", err
+	}
+	return names[0], nil
+}
+
+//remoteRefs returns the hashes of the remote branches and of the tags of the repository
+func remoteRefs(repo *git.Repository) (map[plumbing.ReferenceName]plumbing.Hash, error) {
+	refs, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+	res := map[plumbing.ReferenceName]plumbing.Hash{}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference && (ref.Name().IsRemote() || ref.Name().IsTag()) {
+			res[ref.Name()] = ref.Hash()
+		}
+		return nil
+	})
+	return res, err
+}
+
+//isAncestor returns true if the first commit is an ancestor of the second one
+func isAncestor(repo *git.Repository, ancestor plumbing.Hash, of plumbing.Hash) bool {
+	a, err := repo.CommitObject(ancestor)
+	if err != nil {
+		return false
+	}
+	c, err := repo.CommitObject(of)
+	if err != nil {
+		return false
+	}
+	ok, err := a.IsAncestor(c)
+	return err == nil && ok
+}
+
+//checkoutCommit checks out the commit matching the full or abbreviated hash
+func checkoutCommit(repo *git.Repository, tree *git.Worktree, ref string) error {
+	hash, err := resolveCommit(repo, ref)
+	if err != nil {
+		return err
+	}
+	return tree.Checkout(&git.CheckoutOptions{
+		Hash:  hash,
+		Force: true})
+}
+
+//resolveCommit returns the hash of the commit matching the full or abbreviated hash
+func resolveCommit(repo *git.Repository, ref string) (plumbing.Hash, error) {
+	ref = strings.ToLower(ref)
+	if isFullHash(ref) {
+		c, err := repo.CommitObject(plumbing.NewHash(ref))
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("commit %s is not available: %s", ref, err.Error())
+		}
+		return c.Hash, nil
+	}
+
+	commits, err := repo.CommitObjects()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	var matches []plumbing.Hash
+	err = commits.ForEach(func(c *object.Commit) error {
+		if strings.HasPrefix(c.Hash.String(), ref) {
+			matches = append(matches, c.Hash)
+		}
+		return nil
+	})
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	switch len(matches) {
+	case 0:
+		return plumbing.ZeroHash, fmt.Errorf("no tag, branch or commit named %s", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		candidates := make([]string, 0, len(matches))
+		for _, h := range matches {
+			candidates = append(candidates, h.String())
+		}
+		sort.Strings(candidates)
+		return plumbing.ZeroHash, fmt.Errorf("abbreviated commit hash %s is ambiguous, it matches %s", ref, strings.Join(candidates, ", "))
+	}
+}
+
+//isHashPrefix returns true if the ref can be an abbreviated commit hash
+func isHashPrefix(ref string) bool {
+	if len(ref) < minHashPrefixLength || len(ref) > len(plumbing.ZeroHash.String()) {
+		return false
+	}
+	for _, r := range ref {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
+
+//isFullHash returns true if the ref is a complete commit hash
+func isFullHash(ref string) bool {
+	return len(ref) == len(plumbing.ZeroHash.String()) && isHashPrefix(ref)
+}
+
 func checkout(tree *git.Worktree, ref string) error {
 	return tree.Checkout(&git.CheckoutOptions{
 		Branch: plumbing.ReferenceName(ref),
 		Force:  true})
 }
 
-func buildAuthMethod(auth map[string]string) (transport.AuthMethod, error) {
+//buildAuthMethod returns the GIT authentication method corresponding to the parameters
+//
+//The supported methods are:
+//	basic: HTTP basic authentication using "user" and "password"
+//	token: HTTP token authentication using "token"
+//	password: SSH password authentication using "user" and "password"
+//	key: SSH public key authentication using the private "key", as a file path or as
+//	     inline PEM content, eventually protected by a "passphrase"
+//	agent: SSH public key authentication through the running ssh-agent
+//
+//The SSH methods use the "user" if any, otherwise the user of the location or "git".
+//
+//The SSH methods verify the host keys against the files listed into "known_hosts",
+// the default known_hosts files being used if not specified, unless
+// "insecure_ignore_host_key" is "true".
+func buildAuthMethod(u *url.URL, auth map[string]string) (transport.AuthMethod, error) {
 	if authMethod, ok := auth["method"]; ok {
 		switch authMethod {
 		case "basic":
 			return &http.BasicAuth{Username: auth["user"], Password: auth["password"]}, nil
 		case "password":
-			return &ssh.Password{User: auth["user"], Password: auth["password"]}, nil
+			m := &ssh.Password{User: sshUser(u, auth), Password: auth["password"]}
+			return m, setHostKeyCallback(&m.HostKeyCallbackHelper, auth)
 		case "token":
 			return &http.TokenAuth{Token: auth["token"]}, nil
+		case "key":
+			m, err := buildPublicKeys(u, auth)
+			if err != nil {
+				return nil, err
+			}
+			return m, setHostKeyCallback(&m.HostKeyCallbackHelper, auth)
+		case "agent":
+			m, err := ssh.NewSSHAgentAuth(sshUser(u, auth))
+			if err != nil {
+				return nil, errors.New("unable to use ssh-agent: " + err.Error())
+			}
+			return m, setHostKeyCallback(&m.HostKeyCallbackHelper, auth)
 		default:
 			return nil, errors.New("unknown GIT authentication method")
 		}
 	}
 	return nil, nil
 }
+
+func buildPublicKeys(u *url.URL, auth map[string]string) (*ssh.PublicKeys, error) {
+	key, ok := auth["key"]
+	if !ok || key == "" {
+		return nil, errors.New("missing private key for GIT key authentication")
+	}
+	var m *ssh.PublicKeys
+	var err error
+	if strings.HasPrefix(strings.TrimSpace(key), "-----BEGIN") {
+		m, err = ssh.NewPublicKeys(sshUser(u, auth), []byte(key), auth["passphrase"])
+	} else {
+		m, err = ssh.NewPublicKeysFromFile(sshUser(u, auth), key, auth["passphrase"])
+	}
+	if err != nil {
+		return nil, errors.New("unable to load private key: " + err.Error())
+	}
+	return m, nil
+}
+
+func setHostKeyCallback(h *ssh.HostKeyCallbackHelper, auth map[string]string) error {
+	if auth["insecure_ignore_host_key"] == "true" {
+		h.HostKeyCallback = gossh.InsecureIgnoreHostKey()
+		return nil
+	}
+	if knownHosts := auth["known_hosts"]; knownHosts != "" {
+		cb, err := ssh.NewKnownHostsCallback(filepath.SplitList(knownHosts)...)
+		if err != nil {
+			return errors.New("unable to load known hosts: " + err.Error())
+		}
+		h.HostKeyCallback = cb
+	}
+	// Otherwise the default known_hosts files will be used
+	return nil
+}
+
+//sshUser returns the user of the authentication, or the one of the location if any
+func sshUser(u *url.URL, auth map[string]string) string {
+	if user := auth["user"]; user != "" {
+		return user
+	}
+	if u != nil && u.User != nil && u.User.Username() != "" {
+		return u.User.Username()
+	}
+	return defaultGitSshUser
+}
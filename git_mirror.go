@@ -0,0 +1,209 @@
+package componentizer
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+)
+
+//mirrorLockRetryDelay is the delay between two attempts to lock a mirror used by
+// another process
+const mirrorLockRetryDelay = 100 * time.Millisecond
+
+var (
+	// mirrorLocks serializes the accesses to each mirror of the process, the
+	// processes sharing the cache being serialized through a lock file
+	mirrorLocks   = map[string]*sync.Mutex{}
+	mirrorLocksMu sync.Mutex
+
+	mirrorNameRegexp = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+//WithCache implements "github.com/GroupePSA/componentizer.CacheAware
+func (gitScm GitScmHandler) WithCache(dir string) ScmHandler {
+	gitScm.CacheDir = dir
+	return gitScm
+}
+
+//mirrorPath returns the path of the bare mirror of the repository into the cache
+func (gitScm GitScmHandler) mirrorPath(u *url.URL) string {
+	normalized := normalizeMirrorUrl(u)
+	sum := sha1.Sum([]byte(normalized))
+	name := mirrorNameRegexp.ReplaceAllString(strings.TrimPrefix(normalized, u.Scheme+"://"), "_")
+	return filepath.Join(gitScm.CacheDir, strings.Trim(name, "_")+"-"+hex.EncodeToString(sum[:4])+".git")
+}
+
+//normalizeMirrorUrl returns the url without credentials nor the optional ".git" suffix
+// and with a lower case host, in order to share a mirror between equivalent urls
+func normalizeMirrorUrl(u *url.URL) string {
+	c := *u
+	c.User = nil
+	c.Host = strings.ToLower(c.Host)
+	c.Path = strings.TrimSuffix(strings.TrimSuffix(c.Path, "/"), ".git")
+	c.RawQuery = ""
+	c.Fragment = ""
+	return c.String()
+}
+
+//lockMirror locks the mirror located at the given path for the process and then
+// for the other processes sharing the cache, waiting until the context is done
+func lockMirror(ctx context.Context, path string) (func(), error) {
+	mirrorLocksMu.Lock()
+	l, ok := mirrorLocks[path]
+	if !ok {
+		l = &sync.Mutex{}
+		mirrorLocks[path] = l
+	}
+	mirrorLocksMu.Unlock()
+	l.Lock()
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		l.Unlock()
+		return nil, err
+	}
+	for {
+		unlockFile, locked, err := tryLockFile(path + ".lock")
+		if err != nil {
+			l.Unlock()
+			return nil, errors.New("unable to lock cache mirror " + path + ": " + err.Error())
+		}
+		if locked {
+			return func() {
+				unlockFile()
+				l.Unlock()
+			}, nil
+		}
+		select {
+		case <-ctx.Done():
+			l.Unlock()
+			return nil, canceled(ctx, "lock of cache mirror "+path)
+		case <-time.After(mirrorLockRetryDelay):
+		}
+	}
+}
+
+//isMirrorUsable returns true if the mirror has been completely created, a mirror
+// without remote being left by an interrupted creation
+func isMirrorUsable(path string) bool {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return false
+	}
+	_, err = repo.Remote(defaultGitRemoteName)
+	return err == nil
+}
+
+//syncMirror creates or updates the mirror of the repository and returns its path
+func (gitScm GitScmHandler) syncMirror(ctx context.Context, u *url.URL, auth map[string]string) (string, error) {
+	path := gitScm.mirrorPath(u)
+	unlock, err := lockMirror(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	if DirExist(path) {
+		if isMirrorUsable(path) {
+			return path, gitScm.fetchMirror(ctx, path, auth)
+		}
+		gitScm.Logger.Warn("removing incomplete cache mirror", PathField(path))
+		err = os.RemoveAll(path)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// The mirror is created aside then moved into place once complete
+	source := u.String()
+	gitScm.Logger.Info("creating cache mirror of GIT repository", UrlField(source), PathField(path))
+	tmp, err := ioutil.TempDir(filepath.Dir(path), ".tmp-mirror-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	repo, err := git.PlainInit(tmp, true)
+	if err != nil {
+		return "", errors.New("unable to create cache mirror of git repository " + source + ": " + err.Error())
+	}
+	_, err = repo.CreateRemote(&config.RemoteConfig{
+		Name: defaultGitRemoteName,
+		URLs: []string{source},
+		Fetch: []config.RefSpec{
+			"+refs/heads/*:refs/heads/*",
+			"+refs/tags/*:refs/tags/*",
+		},
+	})
+	if err == nil {
+		err = gitScm.fetchMirror(ctx, tmp, auth)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+//updateMirror updates the existing mirror located at the given path
+func (gitScm GitScmHandler) updateMirror(ctx context.Context, path string, auth map[string]string) error {
+	unlock, err := lockMirror(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return gitScm.fetchMirror(ctx, path, auth)
+}
+
+func (gitScm GitScmHandler) fetchMirror(ctx context.Context, path string, auth map[string]string) error {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return errors.New("unable to open cache mirror " + path + ": " + err.Error())
+	}
+	var remoteUrl *url.URL
+	if remote, err := repo.Remote(defaultGitRemoteName); err == nil {
+		remoteUrl, _ = url.Parse(remote.Config().URLs[0])
+	}
+	authMethod, err := buildAuthMethod(remoteUrl, auth)
+	if err != nil {
+		return errors.New("error updating cache mirror " + path + ": " + err.Error())
+	}
+	gitScm.Logger.Debug("fetching latest data into cache mirror", PathField(path))
+	err = repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: defaultGitRemoteName,
+		Tags:       git.AllTags,
+		Force:      true,
+		Auth:       authMethod})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return errors.New("unable to fetch latest data into cache mirror " + path + ": " + err.Error())
+	}
+	return nil
+}
+
+//shareObjects makes the git directory borrow the objects of the mirror, the objects
+// already available being then never fetched into it
+func shareObjects(gitDir string, mirror string) error {
+	info := filepath.Join(gitDir, "objects", "info")
+	err := os.MkdirAll(info, 0755)
+	if err != nil {
+		return err
+	}
+	objects, err := filepath.Abs(filepath.Join(mirror, "objects"))
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(info, "alternates"), []byte(objects+"\n"), 0644)
+}
@@ -7,6 +7,8 @@ require (
 	github.com/google/uuid v1.1.1
 	github.com/oklog/ulid v1.3.1
 	github.com/stretchr/testify v1.5.1
+	golang.org/x/crypto v0.0.0-20190701094942-4def268fd1a4
+	gopkg.in/src-d/go-billy.v4 v4.3.2
 	gopkg.in/src-d/go-git.v4 v4.13.1
 	gopkg.in/yaml.v2 v2.2.8
 )
@@ -0,0 +1,136 @@
+package componentizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	//EdgeParent links a component to its parent
+	EdgeParent EdgeKind = "parent"
+	//EdgeReference links a component to a component referenced into its descriptor
+	EdgeReference EdgeKind = "reference"
+)
+
+type (
+	//Graph holds the components discovered during an initialization and the
+	// relationships through which they have been discovered
+	Graph struct {
+		// Nodes holds the components in the discovery order
+		Nodes []GraphNode `json:"nodes"`
+		// Edges holds the relationships in the discovery order
+		Edges []GraphEdge `json:"edges"`
+	}
+
+	//GraphNode is a component of the graph
+	GraphNode struct {
+		// Id is the component identifier
+		Id string `json:"id"`
+		// Url is the location of the component repository
+		Url string `json:"url"`
+		// Ref is the reference of the component repository
+		Ref string `json:"ref"`
+		// Retained is true if the component is referenced by the model and then
+		// has been parsed into the final model
+		Retained bool `json:"retained"`
+	}
+
+	//GraphEdge is a relationship between two components of the graph
+	GraphEdge struct {
+		// From is the identifier of the component whose descriptor holds the relationship
+		From string `json:"from"`
+		// To is the identifier of the parent or of the referenced component
+		To string `json:"to"`
+		// Kind is the kind of relationship
+		Kind EdgeKind `json:"kind"`
+	}
+
+	//EdgeKind is the kind of relationship between two components
+	EdgeKind string
+)
+
+//Node returns the node of the component with the given identifier
+func (g Graph) Node(id string) (GraphNode, bool) {
+	for _, n := range g.Nodes {
+		if n.Id == id {
+			return n, true
+		}
+	}
+	return GraphNode{}, false
+}
+
+//WriteJSON writes the graph as an indented JSON document
+func (g Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+//WriteDot writes the graph in the DOT language of Graphviz, the components which
+// have not been retained being dashed
+func (g Graph) WriteDot(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph components {\n")
+	for _, n := range g.Nodes {
+		label := dotEscape(n.Id)
+		if n.Url != "" {
+			label += `\n` + dotEscape(n.Url+"@"+n.Ref)
+		}
+		style := ""
+		if !n.Retained {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&b, "  \"%s\" [label=\"%s\"%s];\n", dotEscape(n.Id), label, style)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  \"%s\" -> \"%s\" [label=\"%s\"];\n", dotEscape(e.From), dotEscape(e.To), e.Kind)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func dotEscape(s string) string {
+	return strings.Replace(strings.Replace(s, `\`, `\\`, -1), `"`, `\"`, -1)
+}
+
+//addNode adds the component to the graph, only once
+func (g *Graph) addNode(c Component) {
+	if _, ok := g.Node(c.ComponentId()); ok {
+		return
+	}
+	g.Nodes = append(g.Nodes, GraphNode{
+		Id:  c.ComponentId(),
+		Url: locationString(c.GetRepository()),
+		Ref: c.GetRepository().Ref,
+	})
+}
+
+//addEdge adds a relationship, and its target if not already present, to the graph
+func (g *Graph) addEdge(from Component, to Component, kind EdgeKind) {
+	g.addNode(to)
+	g.Edges = append(g.Edges, GraphEdge{From: from.ComponentId(), To: to.ComponentId(), Kind: kind})
+}
+
+//retain flags the component as retained, its repository being the one resolved
+// from the temporary model
+func (g *Graph) retain(c Component) {
+	for i, n := range g.Nodes {
+		if n.Id == c.ComponentId() {
+			g.Nodes[i].Retained = true
+			g.Nodes[i].Url = locationString(c.GetRepository())
+			g.Nodes[i].Ref = c.GetRepository().Ref
+			return
+		}
+	}
+}
+
+//copy returns a deep copy of the graph
+func (g Graph) copy() Graph {
+	return Graph{
+		Nodes: append([]GraphNode{}, g.Nodes...),
+		Edges: append([]GraphEdge{}, g.Edges...),
+	}
+}
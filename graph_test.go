@@ -0,0 +1,64 @@
+package componentizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraph(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	parent := tester.CreateDir("parent")
+	parent.WriteCommit("ekara.yaml", "value: parent")
+	comp1 := tester.CreateDir("comp1")
+	comp1.WriteCommit("ekara.yaml", "value: comp1")
+	comp2 := tester.CreateDir("comp2")
+	comp2.WriteCommit("ekara.yaml", "value: comp2")
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", `
+parent:
+  id: parent
+  loc: `+parent.AsRepository("").Loc.String()+`
+components:
+  - id: comp1
+    loc: `+comp1.AsRepository("").Loc.String()+`
+    ref: master
+  - id: comp2
+    loc: `+comp2.AsRepository("").Loc.String()+`
+    unreferenced: true
+value: main
+`)
+
+	err := tester.Init(testComponent{id: "main", repo: main.AsRepository("")})
+	if !assert.Nil(t, err) {
+		return
+	}
+	g := tester.ComponentManager().Graph()
+	assert.Equal(t, []GraphNode{
+		{Id: "main", Url: main.AsRepository("").Loc.String(), Retained: true},
+		{Id: "parent", Url: parent.AsRepository("").Loc.String(), Retained: true},
+		{Id: "comp1", Url: comp1.AsRepository("").Loc.String(), Ref: "master", Retained: true},
+		{Id: "comp2", Url: comp2.AsRepository("").Loc.String(), Retained: false},
+	}, g.Nodes)
+	assert.Equal(t, []GraphEdge{
+		{From: "main", To: "parent", Kind: EdgeParent},
+		{From: "main", To: "comp1", Kind: EdgeReference},
+		{From: "main", To: "comp2", Kind: EdgeReference},
+	}, g.Edges)
+
+	var dot bytes.Buffer
+	assert.Nil(t, g.WriteDot(&dot))
+	assert.Contains(t, dot.String(), `"comp1" [label="comp1\n`+comp1.AsRepository("master").String()+`"];`)
+	assert.Contains(t, dot.String(), `"comp2" [label="comp2\n`+comp2.AsRepository("").String()+`", style=dashed];`)
+	assert.Contains(t, dot.String(), `"main" -> "parent" [label="parent"];`)
+
+	var js bytes.Buffer
+	assert.Nil(t, g.WriteJSON(&js))
+	read := Graph{}
+	assert.Nil(t, json.Unmarshal(js.Bytes(), &read))
+	assert.Equal(t, g, read)
+}
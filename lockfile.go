@@ -0,0 +1,96 @@
+package componentizer
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	//Lockfile records the revisions resolved for the components, allowing to
+	// initialize again exactly the same components.
+	Lockfile struct {
+		// Components holds the locked components sorted by id
+		Components []LockedComponent `yaml:"components"`
+	}
+
+	//LockedComponent records the revision resolved for a component
+	LockedComponent struct {
+		// Id is the component identifier
+		Id string `yaml:"id"`
+		// Url is the location of the component repository
+		Url string `yaml:"url"`
+		// Ref is the reference requested for the component
+		Ref string `yaml:"ref,omitempty"`
+		// Revision is the revision resolved for the reference
+		Revision string `yaml:"revision,omitempty"`
+	}
+
+	//LockedRevisionError is returned when a component can't be switched to
+	// its locked revision
+	LockedRevisionError struct {
+		Id       string
+		Revision string
+		Err      error
+	}
+
+	//UnlockedComponentError is returned in strict lockfile mode when a component
+	// has no lock matching its repository
+	UnlockedComponentError struct {
+		Id string
+		// Outdated is true if the component is locked but its repository or its
+		// reference has been changed since the lockfile creation
+		Outdated bool
+	}
+)
+
+//ReadLockfile reads the lockfile located at the given path
+func ReadLockfile(path string) (Lockfile, error) {
+	l := Lockfile{}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return l, err
+	}
+	err = yaml.Unmarshal(b, &l)
+	return l, err
+}
+
+//Write writes the lockfile at the given path
+func (l Lockfile) Write(path string) error {
+	b, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+//Get returns the locked component corresponding to the id
+func (l Lockfile) Get(id string) (LockedComponent, bool) {
+	for _, c := range l.Components {
+		if c.Id == id {
+			return c, true
+		}
+	}
+	return LockedComponent{}, false
+}
+
+//matches returns true if the lock has been created for the given repository
+func (lc LockedComponent) matches(r Repository) bool {
+	return r.Loc != nil && lc.Url == r.Loc.String() && lc.Ref == r.Ref
+}
+
+func (e *LockedRevisionError) Error() string {
+	return fmt.Sprintf("locked revision %s of component %s is not available: %s", e.Revision, e.Id, e.Err.Error())
+}
+
+func (e *LockedRevisionError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UnlockedComponentError) Error() string {
+	if e.Outdated {
+		return fmt.Sprintf("lock of component %s is outdated", e.Id)
+	}
+	return fmt.Sprintf("component %s is not locked", e.Id)
+}
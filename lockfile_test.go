@@ -0,0 +1,161 @@
+package componentizer
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitWithLockfile(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", "value: v1")
+	locked := main.lastHash.String()
+	mainComp := testComponent{id: "main", repo: main.AsRepository("master")}
+
+	err := tester.Init(mainComp)
+	if !assert.Nil(t, err) {
+		return
+	}
+	lock := tester.ComponentManager().Lock()
+	if assert.Len(t, lock.Components, 1) {
+		assert.Equal(t, LockedComponent{
+			Id:       "main",
+			Url:      mainComp.repo.Loc.String(),
+			Ref:      "master",
+			Revision: locked,
+		}, lock.Components[0])
+	}
+
+	// Round trip through the file system
+	dir, err := ioutil.TempDir("", "componentizer_lock")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	lockPath := filepath.Join(dir, "components.lock")
+	assert.Nil(t, lock.Write(lockPath))
+	lock, err = ReadLockfile(lockPath)
+	assert.Nil(t, err)
+
+	// The locked revision is used even if the branch has moved
+	main.WriteCommit("ekara.yaml", "value: v2")
+	cm := CreateComponentManager(testLogger(), tester.compDir, WithLockfile(lock))
+	m, err := cm.Init(mainComp, tester.TemplateContext())
+	if assert.Nil(t, err) {
+		assert.Equal(t, "v1", m.(testModel).values["main"])
+	}
+
+	// Unavailable revisions are reported
+	lock.Components[0].Revision = "0123456789012345678901234567890123456789"
+	cm = CreateComponentManager(testLogger(), tester.compDir, WithLockfile(lock))
+	_, err = cm.Init(mainComp, tester.TemplateContext())
+	lockErr := &LockedRevisionError{}
+	if assert.True(t, errors.As(err, &lockErr)) {
+		assert.Equal(t, "main", lockErr.Id)
+	}
+
+	// Outdated locks are ignored
+	lock.Components[0].Ref = "other"
+	cm = CreateComponentManager(testLogger(), tester.compDir, WithLockfile(lock))
+	m, err = cm.Init(mainComp, tester.TemplateContext())
+	if assert.Nil(t, err) {
+		assert.Equal(t, "v2", m.(testModel).values["main"])
+	}
+}
+
+func TestLockWithOverrideFromComponent(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	comp2 := tester.CreateDir("comp2")
+	comp2.WriteCommit("ekara.yaml", "value: comp2")
+	comp2b := tester.CreateDir("comp2b")
+	comp2b.WriteCommit("ekara.yaml", "value: comp2b")
+	comp1 := tester.CreateDir("comp1")
+	comp1.WriteCommit("ekara.yaml", `
+overrides:
+  comp2: `+comp2b.AsRepository("").Loc.String()+`
+value: comp1
+`)
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", `
+components:
+  - id: comp1
+    loc: `+comp1.AsRepository("").Loc.String()+`
+  - id: comp2
+    loc: `+comp2.AsRepository("").Loc.String()+`
+value: main
+`)
+	mainComp := testComponent{id: "main", repo: main.AsRepository("")}
+	if !assert.Nil(t, tester.Init(mainComp)) {
+		return
+	}
+
+	// The lock records the repository effectively fetched
+	lock := tester.ComponentManager().Lock()
+	locked, ok := lock.Get("comp2")
+	if assert.True(t, ok) {
+		assert.Equal(t, comp2.AsRepository("").Loc.String(), locked.Url)
+		assert.Equal(t, comp2.lastHash.String(), locked.Revision)
+	}
+	r, _ := tester.ComponentManager().Resolved(testComponent{id: "comp2"})
+	assert.Equal(t, locked.Url, r.Location.String())
+
+	// Then the locked revision is used again
+	comp2.WriteCommit("ekara.yaml", "value: comp2 updated")
+	cm := CreateComponentManager(testLogger(), tester.compDir, WithLockfile(lock))
+	m, err := cm.Init(mainComp, tester.TemplateContext())
+	if assert.Nil(t, err) {
+		assert.Equal(t, "comp2", m.(testModel).values["comp2"])
+	}
+}
+
+func TestInitWithStrictLockfile(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	comp1 := tester.CreateDir("comp1")
+	comp1.WriteCommit("ekara.yaml", "value: comp1")
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", `
+components:
+  - id: comp1
+    loc: `+comp1.AsRepository("").Loc.String()+`
+value: main
+`)
+	mainComp := testComponent{id: "main", repo: main.AsRepository("master")}
+	if !assert.Nil(t, tester.Init(mainComp)) {
+		return
+	}
+	lock := tester.ComponentManager().Lock()
+
+	// All the components are locked
+	cm := CreateComponentManager(testLogger(), tester.compDir, WithStrictLockfile(lock))
+	_, err := cm.Init(mainComp, tester.TemplateContext())
+	assert.Nil(t, err)
+
+	// Outdated locks are reported
+	mainComp.repo.Ref = ""
+	cm = CreateComponentManager(testLogger(), tester.compDir, WithStrictLockfile(lock))
+	_, err = cm.Init(mainComp, tester.TemplateContext())
+	unlockedErr := &UnlockedComponentError{}
+	if assert.True(t, errors.As(err, &unlockedErr)) {
+		assert.Equal(t, "main", unlockedErr.Id)
+		assert.True(t, unlockedErr.Outdated)
+	}
+
+	// Unlocked components are reported
+	mainComp.repo.Ref = "master"
+	lock.Components = lock.Components[1:]
+	cm = CreateComponentManager(testLogger(), tester.compDir, WithStrictLockfile(lock))
+	_, err = cm.Init(mainComp, tester.TemplateContext())
+	if assert.True(t, errors.As(err, &unlockedErr)) {
+		assert.Equal(t, "comp1", unlockedErr.Id)
+		assert.False(t, unlockedErr.Outdated)
+	}
+}
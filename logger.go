@@ -0,0 +1,148 @@
+package componentizer
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+type (
+	//Logger is the leveled and structured logger used to report what the
+	// component manager and the SCM handlers are doing.
+	//
+	//The messages are constant strings, their context being given as fields
+	// in order to be filtered and indexed by the log pipelines.
+	Logger interface {
+		//Debug logs details about the internal processing
+		Debug(msg string, fields ...Field)
+		//Info logs the main steps of the processing
+		Info(msg string, fields ...Field)
+		//Warn logs unexpected situations which don't prevent the processing
+		Warn(msg string, fields ...Field)
+		//Error logs failures
+		Error(msg string, fields ...Field)
+	}
+
+	//Field is a key/value pair giving the context of a logged message
+	Field struct {
+		Key   string
+		Value interface{}
+	}
+
+	//stdLogger writes the messages through a standard logger
+	stdLogger struct {
+		l *log.Logger
+	}
+
+	//nopLogger discards all the messages
+	nopLogger struct{}
+)
+
+const (
+	//FieldComponent is the key of the field holding a component identifier
+	FieldComponent = "component"
+	//FieldUrl is the key of the field holding a repository url
+	FieldUrl = "url"
+	//FieldRef is the key of the field holding a repository reference
+	FieldRef = "ref"
+	//FieldPath is the key of the field holding a local path
+	FieldPath = "path"
+	//FieldDuration is the key of the field holding the duration of an operation
+	FieldDuration = "duration"
+	//FieldError is the key of the field holding an error
+	FieldError = "error"
+)
+
+//NewField returns a field with the given key and value
+func NewField(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+//ComponentField returns the field holding a component identifier
+func ComponentField(id string) Field {
+	return Field{Key: FieldComponent, Value: id}
+}
+
+//UrlField returns the field holding a repository url
+func UrlField(u string) Field {
+	return Field{Key: FieldUrl, Value: u}
+}
+
+//RefField returns the field holding a repository reference
+func RefField(ref string) Field {
+	return Field{Key: FieldRef, Value: ref}
+}
+
+//PathField returns the field holding a local path
+func PathField(path string) Field {
+	return Field{Key: FieldPath, Value: path}
+}
+
+//DurationField returns the field holding the duration of an operation
+func DurationField(d time.Duration) Field {
+	return Field{Key: FieldDuration, Value: d}
+}
+
+//ErrorField returns the field holding an error
+func ErrorField(err error) Field {
+	return Field{Key: FieldError, Value: err}
+}
+
+//NewStdLogger returns a logger writing the messages through a standard logger,
+// as the level followed by the message and the fields formatted as "key=value".
+//
+//A nil standard logger discards all the messages.
+func NewStdLogger(l *log.Logger) Logger {
+	if l == nil {
+		return nopLogger{}
+	}
+	return stdLogger{l: l}
+}
+
+//NopLogger returns a logger discarding all the messages
+func NopLogger() Logger {
+	return nopLogger{}
+}
+
+func (s stdLogger) Debug(msg string, fields ...Field) {
+	s.print("DEBUG", msg, fields)
+}
+
+func (s stdLogger) Info(msg string, fields ...Field) {
+	s.print("INFO", msg, fields)
+}
+
+func (s stdLogger) Warn(msg string, fields ...Field) {
+	s.print("WARN", msg, fields)
+}
+
+func (s stdLogger) Error(msg string, fields ...Field) {
+	s.print("ERROR", msg, fields)
+}
+
+func (s stdLogger) print(level string, msg string, fields []Field) {
+	var b strings.Builder
+	b.WriteString(level)
+	b.WriteString(" ")
+	b.WriteString(msg)
+	for _, f := range fields {
+		v := fmt.Sprint(f.Value)
+		if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+			v = fmt.Sprintf("%q", v)
+		}
+		b.WriteString(" ")
+		b.WriteString(f.Key)
+		b.WriteString("=")
+		b.WriteString(v)
+	}
+	s.l.Println(b.String())
+}
+
+func (nopLogger) Debug(msg string, fields ...Field) {}
+
+func (nopLogger) Info(msg string, fields ...Field) {}
+
+func (nopLogger) Warn(msg string, fields ...Field) {}
+
+func (nopLogger) Error(msg string, fields ...Field) {}
@@ -0,0 +1,49 @@
+//go:build go1.21
+// +build go1.21
+
+package componentizer
+
+import (
+	"context"
+	"log/slog"
+)
+
+//slogLogger writes the messages through a structured logger of the standard library
+type slogLogger struct {
+	l *slog.Logger
+}
+
+//NewSlogLogger returns a logger writing the messages through a slog logger, the
+// fields being converted into attributes.
+//
+//A nil slog logger discards all the messages.
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		return nopLogger{}
+	}
+	return slogLogger{l: l}
+}
+
+func (s slogLogger) Debug(msg string, fields ...Field) {
+	s.log(slog.LevelDebug, msg, fields)
+}
+
+func (s slogLogger) Info(msg string, fields ...Field) {
+	s.log(slog.LevelInfo, msg, fields)
+}
+
+func (s slogLogger) Warn(msg string, fields ...Field) {
+	s.log(slog.LevelWarn, msg, fields)
+}
+
+func (s slogLogger) Error(msg string, fields ...Field) {
+	s.log(slog.LevelError, msg, fields)
+}
+
+func (s slogLogger) log(level slog.Level, msg string, fields []Field) {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, f := range fields {
+		attrs = append(attrs, slog.Any(f.Key, f.Value))
+	}
+	s.l.LogAttrs(context.Background(), level, msg, attrs...)
+}
@@ -0,0 +1,29 @@
+//go:build go1.21
+// +build go1.21
+
+package componentizer
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var b bytes.Buffer
+	l := NewSlogLogger(slog.New(slog.NewTextHandler(&b, &slog.HandlerOptions{
+		Level: slog.LevelWarn,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+
+	l.Info("filtered", ComponentField("comp1"))
+	l.Warn("ignoring outdated lock", ComponentField("comp1"), RefField("v1.0.0"))
+	assert.Equal(t, "level=WARN msg=\"ignoring outdated lock\" component=comp1 ref=v1.0.0\n", b.String())
+}
@@ -0,0 +1,28 @@
+package componentizer
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStdLogger(t *testing.T) {
+	var b bytes.Buffer
+	l := NewStdLogger(log.New(&b, "", 0))
+
+	l.Info("component fetched", ComponentField("comp1"), UrlField("file:///tmp/comp1"), RefField(""), DurationField(2*time.Second))
+	l.Error("error fetching the component", ErrorField(errors.New("no such repository")))
+	assert.Equal(t, `INFO component fetched component=comp1 url=file:///tmp/comp1 ref="" duration=2s
+ERROR error fetching the component error="no such repository"
+`, b.String())
+}
+
+func TestNilStdLogger(t *testing.T) {
+	l := NewStdLogger(nil)
+	assert.Equal(t, NopLogger(), l)
+	l.Warn("discarded", ComponentField("comp1"))
+}
@@ -0,0 +1,162 @@
+package componentizer
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	//EventComponentDiscovered is emitted when a component is found into a descriptor,
+	// or as the parent of a component
+	EventComponentDiscovered EventType = "component_discovered"
+	//EventFetchStarted is emitted before fetching a component from a location
+	EventFetchStarted EventType = "fetch_started"
+	//EventFetchFinished is emitted once a component has been fetched from a location,
+	// successfully or not
+	EventFetchFinished EventType = "fetch_finished"
+	//EventRefSwitched is emitted once a fetched component has been switched to its reference
+	EventRefSwitched EventType = "ref_switched"
+	//EventModelParsed is emitted once the model of a retained component has been parsed
+	EventModelParsed EventType = "model_parsed"
+	//EventMergeDone is emitted once the models of all the retained components have been merged
+	EventMergeDone EventType = "merge_done"
+	//EventTemplatedCopyCreated is emitted when a templated copy of a component is created
+	EventTemplatedCopyCreated EventType = "templated_copy_created"
+	//EventTemplatedCopyReleased is emitted when a templated copy of a component is released
+	EventTemplatedCopyReleased EventType = "templated_copy_released"
+)
+
+type (
+	//EventType identifies the kind of a lifecycle event
+	EventType string
+
+	//Event describes something which happened into a component manager, only the
+	// fields relevant for the event type are set.
+	Event struct {
+		// Type is the kind of event
+		Type EventType
+		// Time is the moment the event has been emitted
+		Time time.Time
+		// ComponentId is the identifier of the component concerned by the event
+		ComponentId string
+		// DiscoveredBy is the identifier of the component whose descriptor introduced
+		// the discovered component, empty for the main component
+		DiscoveredBy string
+		// Url is the location of the component repository
+		Url string
+		// Ref is the reference of the component repository
+		Ref string
+		// Revision is the exact revision the component has been switched to, if known
+		Revision string
+		// Path is the local path of the component, or of its templated copy
+		Path string
+		// WorkTreeSize is the size of the files of a component fetched from scratch,
+		// the SCM metadata excluded; it is not the amount of data transferred and
+		// it is not computed when an existing component is updated
+		WorkTreeSize int64
+		// Duration is the time spent by the operation
+		Duration time.Duration
+		// Err is the error which made the operation fail, if any
+		Err error
+	}
+
+	//Observer is notified of the lifecycle events of a component manager.
+	//
+	//The components being fetched concurrently, an observer must be safe for
+	// concurrent use.
+	Observer interface {
+		//Notify is called for each event, it must not block
+		Notify(e Event)
+	}
+
+	//ObserverFunc is an adapter allowing to use a function as Observer
+	ObserverFunc func(e Event)
+)
+
+//Notify implements Observer
+func (f ObserverFunc) Notify(e Event) {
+	f(e)
+}
+
+//notify sends the event to all the observers of the component manager
+func (cm *componentManager) notify(e Event) {
+	if len(cm.observers) == 0 {
+		return
+	}
+	e.Time = time.Now()
+	for _, o := range cm.observers {
+		o.Notify(e)
+	}
+}
+
+//notifyDiscovered emits the discovery of a component into the descriptor of another one
+func (cm *componentManager) notifyDiscovered(by Component, c Component) {
+	cm.notify(Event{
+		Type:         EventComponentDiscovered,
+		ComponentId:  c.ComponentId(),
+		DiscoveredBy: by.ComponentId(),
+		Url:          locationString(c.GetRepository()),
+		Ref:          c.GetRepository().Ref,
+	})
+}
+
+//notifyFetched emits the events ending the fetch of a component from a location
+func (cm *componentManager) notifyFetched(c Component, loc *url.URL, start time.Time, fComp fetchedComponent, err error) {
+	if len(cm.observers) == 0 {
+		return
+	}
+	e := Event{
+		Type:        EventFetchFinished,
+		ComponentId: c.ComponentId(),
+		Url:         loc.String(),
+		Ref:         c.GetRepository().Ref,
+		Duration:    time.Since(start),
+		Err:         err,
+	}
+	if err == nil {
+		cm.notify(Event{
+			Type:        EventRefSwitched,
+			ComponentId: c.ComponentId(),
+			Url:         loc.String(),
+			Ref:         fComp.ref,
+			Revision:    fComp.revision,
+			Path:        fComp.rootPath,
+		})
+		e.Ref = fComp.ref
+		e.Revision = fComp.revision
+		e.Path = fComp.rootPath
+		if fComp.fresh {
+			e.WorkTreeSize = dirSize(fComp.rootPath)
+		}
+	}
+	cm.notify(e)
+}
+
+//locationString returns the location of the repository, if any
+func locationString(r Repository) string {
+	if r.Loc == nil {
+		return ""
+	}
+	return r.Loc.String()
+}
+
+//dirSize returns the size of the files located into the directory, ignoring the
+// SCM metadata directories
+func dirSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && (info.Name() == ".git" || info.Name() == ".svn") {
+			return filepath.SkipDir
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
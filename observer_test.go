@@ -0,0 +1,133 @@
+package componentizer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type templatedTestComponent struct {
+	testComponent
+}
+
+func (c templatedTestComponent) Component(model interface{}) (Component, error) {
+	return c, nil
+}
+
+func (c templatedTestComponent) GetTemplates() (bool, []string) {
+	return true, []string{"*.yaml"}
+}
+
+func TestObserverEvents(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	comp1 := tester.CreateDir("comp1")
+	comp1.WriteCommit("ekara.yaml", "value: comp1")
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", `
+components:
+  - id: comp1
+    loc: `+comp1.AsRepository("").Loc.String()+`
+value: main
+`)
+
+	var mu sync.Mutex
+	var events []Event
+	cm := CreateComponentManager(testLogger(), tester.compDir, WithObserver(ObserverFunc(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	})))
+	mainComp := templatedTestComponent{testComponent{id: "main", repo: main.AsRepository("")}}
+	_, err := cm.Init(mainComp, tester.TemplateContext())
+	if !assert.Nil(t, err) {
+		return
+	}
+	u, err := cm.Use(mainComp, tester.TemplateContext())
+	if assert.Nil(t, err) && assert.True(t, u.Templated()) {
+		u.Release()
+	}
+
+	byType := map[EventType][]Event{}
+	for _, e := range events {
+		assert.False(t, e.Time.IsZero())
+		byType[e.Type] = append(byType[e.Type], e)
+	}
+	if assert.Len(t, byType[EventComponentDiscovered], 2) {
+		assert.Equal(t, "main", byType[EventComponentDiscovered][0].ComponentId)
+		assert.Equal(t, "", byType[EventComponentDiscovered][0].DiscoveredBy)
+		assert.Equal(t, "comp1", byType[EventComponentDiscovered][1].ComponentId)
+		assert.Equal(t, "main", byType[EventComponentDiscovered][1].DiscoveredBy)
+	}
+	assert.Len(t, byType[EventFetchStarted], 2)
+	if assert.Len(t, byType[EventFetchFinished], 2) {
+		for _, e := range byType[EventFetchFinished] {
+			assert.Nil(t, e.Err)
+			if e.ComponentId == "comp1" {
+				// The SCM metadata are not counted
+				assert.Equal(t, int64(len("value: comp1")), e.WorkTreeSize)
+			} else {
+				assert.True(t, e.WorkTreeSize > 0)
+			}
+			assert.NotEmpty(t, e.Revision)
+		}
+	}
+	assert.Len(t, byType[EventRefSwitched], 2)
+	assert.Len(t, byType[EventModelParsed], 2)
+	if assert.Len(t, byType[EventMergeDone], 1) {
+		assert.Equal(t, "main", byType[EventMergeDone][0].ComponentId)
+	}
+	if assert.Len(t, byType[EventTemplatedCopyCreated], 1) && assert.Len(t, byType[EventTemplatedCopyReleased], 1) {
+		assert.Equal(t, byType[EventTemplatedCopyCreated][0].Path, byType[EventTemplatedCopyReleased][0].Path)
+		assert.False(t, DirExist(byType[EventTemplatedCopyReleased][0].Path))
+	}
+
+	// The size is not computed for the update of a component already fetched
+	mu.Lock()
+	events = nil
+	mu.Unlock()
+	_, err = cm.Refresh(testComponent{id: "comp1"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	fetched := 0
+	for _, e := range events {
+		if e.Type == EventFetchFinished {
+			fetched++
+			assert.Nil(t, e.Err)
+			assert.Equal(t, int64(0), e.WorkTreeSize)
+		}
+	}
+	assert.Equal(t, 1, fetched)
+}
+
+func TestObserverWorkTreeSizeOfFetchAgain(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	// A plain directory is copied again by each initialization
+	src := filepath.Join(tester.fixDir, "main")
+	assert.Nil(t, os.MkdirAll(src, 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(src, "ekara.yaml"), []byte("value: main"), 0644))
+	repo, err := CreateRepository("file://"+src, "", nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+	for i := 0; i < 2; i++ {
+		var sizes []int64
+		cm := CreateComponentManager(testLogger(), tester.compDir, WithObserver(ObserverFunc(func(e Event) {
+			if e.Type == EventFetchFinished {
+				sizes = append(sizes, e.WorkTreeSize)
+			}
+		})))
+		_, err = cm.Init(testComponent{id: "main", repo: repo}, tester.TemplateContext())
+		if assert.Nil(t, err) {
+			assert.Equal(t, []int64{int64(len("value: main"))}, sizes)
+		}
+	}
+}
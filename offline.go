@@ -0,0 +1,31 @@
+package componentizer
+
+import (
+	"errors"
+	"strings"
+)
+
+type (
+	//OfflineError is returned by an offline initialization when components have
+	// not been fetched into the work directory
+	OfflineError struct {
+		// Missing holds the components to fetch before working offline
+		Missing []MissingComponent
+	}
+
+	//MissingComponent is a component missing from the work directory
+	MissingComponent struct {
+		Id         string
+		Repository Repository
+	}
+)
+
+var errNotAvailableOffline = errors.New("not available offline")
+
+func (e *OfflineError) Error() string {
+	missing := make([]string, 0, len(e.Missing))
+	for _, m := range e.Missing {
+		missing = append(missing, m.Id+" ("+m.Repository.String()+")")
+	}
+	return "components must be fetched before working offline: " + strings.Join(missing, ", ")
+}
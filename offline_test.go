@@ -0,0 +1,71 @@
+package componentizer
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type offlineScmHandler struct {
+	remoteAccess *int
+	switched     *string
+}
+
+func (h offlineScmHandler) Matches(u *url.URL, path string) bool { return true }
+
+func (h offlineScmHandler) Fetch(ctx context.Context, u *url.URL, path string, auth map[string]string) error {
+	*h.remoteAccess++
+	return nil
+}
+
+func (h offlineScmHandler) Update(ctx context.Context, path string, auth map[string]string) (bool, error) {
+	*h.remoteAccess++
+	return false, nil
+}
+
+func (h offlineScmHandler) Switch(ctx context.Context, path string, ref string) error {
+	*h.switched = ref
+	return nil
+}
+
+func TestFetchOffline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "componentizer_offline")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "present"), 0755))
+
+	remoteAccess, switched := 0, ""
+	h := offlineScmHandler{remoteAccess: &remoteAccess, switched: &switched}
+	repo, err := CreateRepository("https://github.com/ekara-platform/present", "v1.0.0", nil)
+	assert.Nil(t, err)
+
+	_, err = fetchThroughSCM(testComponent{id: "present", repo: repo}, h, repo.Loc, dir, nil, fetchSettings{offline: true})(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "v1.0.0", switched)
+
+	_, err = fetchThroughSCM(testComponent{id: "missing", repo: repo}, h, repo.Loc, dir, nil, fetchSettings{offline: true})(context.Background())
+	assert.True(t, errors.Is(err, errNotAvailableOffline))
+	assert.Equal(t, 0, remoteAccess)
+}
+
+func TestInitOfflineMissing(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	repo, err := CreateRepository("https://github.com/ekara-platform/missing", "", nil)
+	assert.Nil(t, err)
+	cm := CreateComponentManager(testLogger(), tester.compDir, WithOffline())
+	_, err = cm.Init(testComponent{id: "missing", repo: repo}, tester.TemplateContext())
+
+	offlineErr := &OfflineError{}
+	if assert.True(t, errors.As(err, &offlineErr)) && assert.Len(t, offlineErr.Missing, 1) {
+		assert.Equal(t, "missing", offlineErr.Missing[0].Id)
+		assert.Equal(t, repo, offlineErr.Missing[0].Repository)
+	}
+}
@@ -0,0 +1,113 @@
+package componentizer
+
+import "path/filepath"
+
+//ManagerOption customizes a component manager at creation time
+type ManagerOption func(cm *componentManager)
+
+//WithScmHandler overrides, only for the created component manager, the factory used
+// to create SCM handlers for repositories located with the given url scheme.
+func WithScmHandler(scheme string, factory ScmHandlerFactory) ManagerOption {
+	return func(cm *componentManager) {
+		cm.scmHandlers[scheme] = factory
+	}
+}
+
+//WithCredentialProviders adds providers consulted, in order, to get the authentication
+// parameters of the repositories which don't hold any.
+func WithCredentialProviders(providers ...CredentialProvider) ManagerOption {
+	return func(cm *componentManager) {
+		cm.credentials = append(cm.credentials, providers...)
+	}
+}
+
+//WithLockfile makes the component manager switch the components to the revisions
+// recorded into the lockfile. The lock of a component is ignored if its repository
+// or its reference has been changed since the lockfile creation.
+func WithLockfile(lock Lockfile) ManagerOption {
+	return func(cm *componentManager) {
+		cm.lock = &lock
+		cm.strictLock = false
+	}
+}
+
+//WithStrictLockfile is like WithLockfile but the initialization fails with an
+// UnlockedComponentError when a component is not locked, or when its repository or
+// its reference has been changed since the lockfile creation.
+func WithStrictLockfile(lock Lockfile) ManagerOption {
+	return func(cm *componentManager) {
+		cm.lock = &lock
+		cm.strictLock = true
+	}
+}
+
+//WithoutLockfile makes the component manager ignore the lockfile it has eventually
+// been created with, the components being switched to their references.
+func WithoutLockfile() ManagerOption {
+	return func(cm *componentManager) {
+		cm.lock = nil
+		cm.strictLock = false
+	}
+}
+
+//WithOffline prevents the component manager to access remote repositories. The
+// components must have been fetched into the work directory, where they are only
+// switched to the desired references.
+func WithOffline() ManagerOption {
+	return func(cm *componentManager) {
+		cm.offline = true
+	}
+}
+
+//WithCacheDir makes the component manager share the repositories data through the
+// given cache directory, which can be used by several managers working into
+// different work directories. Only the SCM handlers implementing CacheAware use it.
+func WithCacheDir(dir string) ManagerOption {
+	return func(cm *componentManager) {
+		// Mirrors are cloned through their path which must not depend on the current directory
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		cm.cacheDir = dir
+	}
+}
+
+//WithRewriteRules makes the component manager rewrite the repository locations
+// before fetching them.
+func WithRewriteRules(rules ...RewriteRule) ManagerOption {
+	return func(cm *componentManager) {
+		cm.rewrites = append(cm.rewrites, rules...)
+	}
+}
+
+//WithFallbacks makes the component manager try fallback locations when a repository
+// can't be fetched from its location, after the rewrite rules have been applied.
+func WithFallbacks(rules ...FallbackRule) ManagerOption {
+	return func(cm *componentManager) {
+		cm.fallbacks = append(cm.fallbacks, rules...)
+	}
+}
+
+//WithObserver adds observers notified of the lifecycle events of the component manager.
+func WithObserver(observers ...Observer) ManagerOption {
+	return func(cm *componentManager) {
+		cm.observers = append(cm.observers, observers...)
+	}
+}
+
+//WithMaxParentDepth limits the number of parents above the main component, deeper
+// parent chains making the initialization fail with a ParentDepthError. There is
+// no limit by default.
+func WithMaxParentDepth(depth int) ManagerOption {
+	return func(cm *componentManager) {
+		cm.maxParentDepth = depth
+	}
+}
+
+//WithFetchWorkers sets the maximum number of components fetched concurrently
+// during the initialization.
+func WithFetchWorkers(workers int) ManagerOption {
+	return func(cm *componentManager) {
+		cm.workers = workers
+	}
+}
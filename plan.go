@@ -0,0 +1,228 @@
+package componentizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oklog/ulid"
+)
+
+const (
+	//ActionFetch means that the component will be fetched from scratch
+	ActionFetch PlanAction = "fetch"
+	//ActionUpdate means that the latest data of the component will be fetched
+	ActionUpdate PlanAction = "update"
+	//ActionSwitch means that the component will be switched to another revision already fetched
+	ActionSwitch PlanAction = "switch"
+	//ActionRemove means that the component is not part of the resolution anymore
+	ActionRemove PlanAction = "remove"
+	//ActionUnchanged means that the component is already at the desired revision
+	ActionUnchanged PlanAction = "unchanged"
+)
+
+type (
+	//Plan describes what an initialization would do to the work directory
+	Plan struct {
+		// Components holds the retained components in the parsing order followed
+		// by the components to remove
+		Components []PlannedComponent
+	}
+
+	//PlannedComponent describes what an initialization would do to a component
+	PlannedComponent struct {
+		// Id is the component identifier
+		Id string
+		// Action is what would be done to the component
+		Action PlanAction
+		// Repository is the repository of the component resolved from the model
+		Repository Repository
+		// Location is the location the component would be fetched from, after the
+		// rewrite rules have been applied
+		Location *url.URL
+		// Ref is the reference the component would be switched to
+		Ref string
+		// Revision is the revision the component would be switched to, empty if it
+		// can't be determined without fetching the component
+		Revision string
+		// CurrentRevision is the revision of the component into the work directory, if known
+		CurrentRevision string
+	}
+
+	//PlanAction is what an initialization would do to a component
+	PlanAction string
+)
+
+//Changed returns the components which would not be left unchanged
+func (p Plan) Changed() []PlannedComponent {
+	var res []PlannedComponent
+	for _, c := range p.Components {
+		if c.Action != ActionUnchanged {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+func (p Plan) String() string {
+	var b strings.Builder
+	for _, c := range p.Components {
+		fmt.Fprintf(&b, "%-9s %s", c.Action, c.Id)
+		if c.Action != ActionRemove {
+			fmt.Fprintf(&b, " %s@%s", c.Location, c.Ref)
+			if c.Revision != "" {
+				fmt.Fprintf(&b, " (%s)", c.Revision)
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (cm *componentManager) Plan(main Component, tplC TemplateContext) (Plan, error) {
+	return cm.PlanContext(context.Background(), main, tplC)
+}
+
+func (cm *componentManager) PlanContext(ctx context.Context, main Component, tplC TemplateContext) (Plan, error) {
+	if cm.offline {
+		return Plan{}, errors.New("a plan can't be computed offline")
+	}
+	dir, err := ioutil.TempDir("", "componentizer_plan")
+	if err != nil {
+		return Plan{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	// The descriptors are fetched into a temporary work directory
+	pm := cm.derive(dir)
+	d := newDiscovery(main, tplC)
+	tempModel, comps, err := pm.findComponents(ctx, main, tplC, nil, d)
+	if err != nil {
+		return Plan{}, err
+	}
+	retained, err := retainComponents(tempModel, comps, d)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	p := Plan{}
+	planned := map[string]bool{}
+	for _, c := range retained {
+		if planned[c.ComponentId()] {
+			continue
+		}
+		planned[c.ComponentId()] = true
+		pc, err := cm.planComponent(ctx, pm, c)
+		if err != nil {
+			return Plan{}, err
+		}
+		p.Components = append(p.Components, pc)
+	}
+
+	// Look for components of the work directory which are not retained anymore
+	entries, err := ioutil.ReadDir(cm.directory)
+	if err != nil && !os.IsNotExist(err) {
+		return Plan{}, err
+	}
+	for _, e := range entries {
+		if e.IsDir() && !planned[e.Name()] && !isTemplatedCopy(e.Name()) {
+			p.Components = append(p.Components, PlannedComponent{Id: e.Name(), Action: ActionRemove})
+		}
+	}
+	return p, nil
+}
+
+//planComponent computes the action required to make the component of the work directory
+// match the target, which is either the component fetched by the planner or the remote
+// revision of the component
+func (cm *componentManager) planComponent(ctx context.Context, pm *componentManager, c Component) (PlannedComponent, error) {
+	pc := PlannedComponent{Id: c.ComponentId(), Repository: c.GetRepository()}
+	s, err := cm.fetchSettings(c)
+	if err != nil {
+		return pc, err
+	}
+
+	var scm ScmHandler
+	var auth map[string]string
+	target, fetched := pm.isComponentFetched(c.ComponentId())
+	if fetched {
+		s.loc = target.location
+		scm, pc.Location, auth, err = createScmHandler(ctx, cm.l, c, s)
+		if err != nil {
+			return pc, err
+		}
+		pc.Ref, pc.Revision = target.ref, target.revision
+	} else {
+		locs, err := cm.candidateLocations(c.GetRepository())
+		if err != nil {
+			return pc, err
+		}
+		for _, loc := range locs {
+			s.loc = loc
+			scm, pc.Location, auth, err = createScmHandler(ctx, cm.l, c, s)
+			if err != nil {
+				continue
+			}
+			pc.Ref, pc.Revision, err = cm.targetRevision(ctx, scm, c, pc.Location, auth, s)
+			if err == nil {
+				break
+			}
+			cm.l.Warn("unable to inspect the component", ComponentField(c.ComponentId()), UrlField(loc.String()), ErrorField(err))
+		}
+		if err != nil {
+			return pc, err
+		}
+	}
+
+	// Compare with the content of the work directory
+	path := filepath.Join(cm.directory, c.ComponentId())
+	if !DirExist(path) || !scm.Matches(pc.Location, path) {
+		pc.Action = ActionFetch
+		return pc, nil
+	}
+	if r, ok := scm.(RevisionReader); ok {
+		pc.CurrentRevision, _ = r.Revision(path)
+	}
+	pc.Action = ActionUpdate
+	if pc.Revision == "" {
+		return pc, nil
+	}
+	if ri, ok := scm.(RemoteInspector); ok {
+		if local, ok := ri.LocalRevision(path, pc.Revision); ok {
+			if local == pc.CurrentRevision {
+				pc.Action = ActionUnchanged
+			} else {
+				pc.Action = ActionSwitch
+			}
+		}
+	} else if pc.Revision == pc.CurrentRevision {
+		pc.Action = ActionUnchanged
+	}
+	return pc, nil
+}
+
+//targetRevision returns the reference and the revision the component would be switched to
+func (cm *componentManager) targetRevision(ctx context.Context, scm ScmHandler, c Component, loc *url.URL, auth map[string]string, s fetchSettings) (string, string, error) {
+	if s.revision != "" {
+		return s.revision, s.revision, nil
+	}
+	if ri, ok := scm.(RemoteInspector); ok {
+		return ri.RemoteRevision(ctx, loc, c.GetRepository().Ref, auth)
+	}
+	return c.GetRepository().Ref, "", nil
+}
+
+//isTemplatedCopy returns true if the directory name is the one of a templated component
+func isTemplatedCopy(name string) bool {
+	idx := strings.LastIndex(name, "_")
+	if idx == -1 {
+		return false
+	}
+	_, err := ulid.Parse(name[idx+1:])
+	return err == nil
+}
@@ -0,0 +1,90 @@
+package componentizer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func planActions(p Plan) map[string]PlanAction {
+	res := map[string]PlanAction{}
+	for _, c := range p.Components {
+		res[c.Id] = c.Action
+	}
+	return res
+}
+
+func TestPlan(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	parent := tester.CreateDir("parent")
+	parent.WriteCommit("ekara.yaml", "value: parent")
+	comp1 := tester.CreateDir("comp1")
+	comp1.WriteCommit("ekara.yaml", "value: comp1")
+	comp2 := tester.CreateDir("comp2")
+	comp2.WriteCommit("ekara.yaml", "value: v1")
+	comp2.Tag("v1.0.0")
+	comp2.WriteCommit("ekara.yaml", "value: v2")
+	comp2.Tag("v2.0.0")
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", `
+parent:
+  id: parent
+  loc: `+parent.AsRepository("").Loc.String()+`
+components:
+  - id: comp1
+    loc: `+comp1.AsRepository("").Loc.String()+`
+  - id: comp2
+    loc: `+comp2.AsRepository("").Loc.String()+`
+    ref: ^1.0
+value: main
+`)
+	mainComp := testComponent{id: "main", repo: main.AsRepository("")}
+	cm := tester.ComponentManager()
+
+	// Nothing has been fetched yet
+	p, err := cm.Plan(mainComp, tester.TemplateContext())
+	if assert.Nil(t, err) {
+		assert.Equal(t, map[string]PlanAction{"parent": ActionFetch, "comp1": ActionFetch, "comp2": ActionFetch, "main": ActionFetch}, planActions(p))
+		assert.Equal(t, "v1.0.0", p.Components[2].Ref)
+		assert.NotEmpty(t, p.Components[2].Revision)
+	}
+	assert.False(t, DirExist(tester.compDir))
+
+	// Everything is up-to-date after the initialization
+	if !assert.Nil(t, tester.Init(mainComp)) {
+		return
+	}
+	p, err = cm.Plan(mainComp, tester.TemplateContext())
+	if assert.Nil(t, err) {
+		assert.Equal(t, map[string]PlanAction{"parent": ActionUnchanged, "comp1": ActionUnchanged, "comp2": ActionUnchanged, "main": ActionUnchanged}, planActions(p))
+		assert.Empty(t, p.Changed())
+	}
+
+	// New commits have to be fetched
+	comp1.WriteCommit("ekara.yaml", "value: comp1 updated")
+	p, err = cm.Plan(mainComp, tester.TemplateContext())
+	if assert.Nil(t, err) {
+		assert.Equal(t, ActionUpdate, planActions(p)["comp1"])
+		assert.Len(t, p.Changed(), 1)
+	}
+
+	// Moved references and dropped components
+	main.WriteCommit("ekara.yaml", `
+parent:
+  id: parent
+  loc: `+parent.AsRepository("").Loc.String()+`
+components:
+  - id: comp2
+    loc: `+comp2.AsRepository("").Loc.String()+`
+    ref: ^2.0
+value: main
+`)
+	p, err = cm.Plan(mainComp, tester.TemplateContext())
+	if assert.Nil(t, err) {
+		assert.Equal(t, map[string]PlanAction{"parent": ActionUnchanged, "comp1": ActionRemove, "comp2": ActionSwitch, "main": ActionUpdate}, planActions(p))
+	}
+	assertTestFileContent(t, filepath.Join(tester.compDir, "comp1", "ekara.yaml"), "value: comp1")
+}
@@ -0,0 +1,106 @@
+package componentizer
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+func (cm *componentManager) Refresh(cr ComponentRef) (bool, error) {
+	return cm.RefreshContext(context.Background(), cr)
+}
+
+func (cm *componentManager) RefreshContext(ctx context.Context, cr ComponentRef) (bool, error) {
+	return cm.refresh(ctx, cr.ComponentId(), false)
+}
+
+//refresh updates the component with the given identifier and merges the models again,
+// the working tree of a local git repository being copied if workTree is true
+func (cm *componentManager) refresh(ctx context.Context, id string, workTree bool) (bool, error) {
+	cm.initMu.Lock()
+	defer cm.initMu.Unlock()
+
+	cm.mu.RLock()
+	fComp, ok := cm.fComps[id]
+	d := cm.discovery
+	cm.mu.RUnlock()
+	if !ok || d == nil {
+		return false, fmt.Errorf("component %s is not available", id)
+	}
+	start := time.Now()
+
+	// Update the local content, the component being already fetched
+	rComp, err := cm.doFetchComponent(ctx, fComp.component, workTree)
+	if err != nil {
+		return false, err
+	}
+	cModel, err := fComp.component.ParseModel(rComp.rootPath, d.tplC)
+	if err != nil {
+		return false, err
+	}
+	cm.notify(Event{Type: EventModelParsed, ComponentId: id, Path: rComp.rootPath})
+
+	// Merge the models again in the parsing order
+	cm.mu.RLock()
+	order := cm.order
+	models := make(map[string]Model, len(cm.models))
+	for k, v := range cm.models {
+		models[k] = v
+	}
+	previous := cm.model
+	cm.mu.RUnlock()
+	if _, ok := models[id]; ok {
+		models[id] = cModel
+	}
+	var fModel Model
+	for _, oId := range order {
+		if err := canceled(ctx, "refresh of component "+id); err != nil {
+			return false, err
+		}
+		oModel := models[oId]
+		if oModel == nil {
+			continue
+		}
+		if fModel != nil {
+			fModel, err = fModel.Merge(oModel)
+			if err != nil {
+				return false, err
+			}
+		} else {
+			fModel = oModel
+		}
+	}
+	changed, err := modelChanged(previous, fModel)
+	if err != nil {
+		return false, err
+	}
+
+	cm.mu.Lock()
+	cm.fComps[id] = rComp
+	cm.models = models
+	cm.model = fModel
+	err = cm.resolveFetched(fModel)
+	cm.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+	cm.l.Info("component refreshed", ComponentField(id), PathField(rComp.rootPath), DurationField(time.Since(start)))
+	cm.notify(Event{Type: EventMergeDone, ComponentId: id, Duration: time.Since(start)})
+	return changed, nil
+}
+
+//modelChanged returns true if the models differ, using Diffable if implemented
+func modelChanged(from Model, to Model) (bool, error) {
+	if from == nil || to == nil {
+		return from != to, nil
+	}
+	if diffable, ok := from.(Diffable); ok {
+		changes, err := diffable.Diff(to)
+		if err != nil {
+			return false, err
+		}
+		return len(changes) > 0, nil
+	}
+	return !reflect.DeepEqual(from, to), nil
+}
@@ -0,0 +1,122 @@
+package componentizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefresh(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	comp1 := tester.CreateDir("comp1")
+	comp1.WriteCommit("ekara.yaml", "value: v1")
+	comp2 := tester.CreateDir("comp2")
+	comp2.WriteCommit("ekara.yaml", "value: comp2")
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", `
+components:
+  - id: comp1
+    loc: `+comp1.AsRepository("").Loc.String()+`
+  - id: comp2
+    loc: `+comp2.AsRepository("").Loc.String()+`
+value: main
+`)
+	mainComp := testComponent{id: "main", repo: main.AsRepository("")}
+	cm := tester.ComponentManager()
+	if !assert.Nil(t, tester.Init(mainComp)) {
+		return
+	}
+
+	// Nothing new to fetch
+	changed, err := cm.Refresh(testComponent{id: "comp1"})
+	if assert.Nil(t, err) {
+		assert.False(t, changed)
+	}
+
+	// A new commit changes the model
+	comp1.WriteCommit("ekara.yaml", "value: v2")
+	changed, err = cm.Refresh(testComponent{id: "comp1"})
+	if assert.Nil(t, err) {
+		assert.True(t, changed)
+		assert.Equal(t, map[string]string{"comp1": "v2", "comp2": "comp2", "main": "main"}, cm.Model().(testModel).values)
+		r, _ := cm.Resolved(testComponent{id: "comp1"})
+		assert.Equal(t, comp1.lastHash.String(), r.Revision)
+	}
+	assertTestFileContent(t, tester.compDir+"/comp1/ekara.yaml", "value: v2")
+
+	// Unknown components can't be refreshed
+	_, err = cm.Refresh(testComponent{id: "comp3"})
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"comp1", "comp2", "main"}, cm.ComponentOrder())
+}
+
+func TestInitTwiceKeepsOrder(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	comp1 := tester.CreateDir("comp1")
+	comp1.WriteCommit("ekara.yaml", "value: comp1")
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", `
+components:
+  - id: comp1
+    loc: `+comp1.AsRepository("").Loc.String()+`
+value: main
+`)
+	mainComp := testComponent{id: "main", repo: main.AsRepository("")}
+	cm := tester.ComponentManager()
+	for i := 0; i < 2; i++ {
+		if assert.Nil(t, tester.Init(mainComp)) {
+			assert.Equal(t, []string{"comp1", "main"}, cm.ComponentOrder())
+		}
+	}
+}
+
+func TestRefreshOverride(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	comp1 := tester.CreateDir("comp1")
+	comp1.WriteCommit("ekara.yaml", "value: comp1")
+	comp2 := tester.CreateDir("comp2")
+	comp2.WriteCommit("ekara.yaml", "value: comp2")
+	comp2b := tester.CreateDir("comp2b")
+	comp2b.WriteCommit("ekara.yaml", "value: comp2b")
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", `
+components:
+  - id: comp1
+    loc: `+comp1.AsRepository("").Loc.String()+`
+  - id: comp2
+    loc: `+comp2.AsRepository("").Loc.String()+`
+value: main
+`)
+	cm := tester.ComponentManager()
+	if !assert.Nil(t, tester.Init(testComponent{id: "main", repo: main.AsRepository("")})) {
+		return
+	}
+
+	// The override added by comp1 applies to comp2
+	comp1.WriteCommit("ekara.yaml", `
+overrides:
+  comp2: `+comp2b.AsRepository("").Loc.String()+`
+value: comp1
+`)
+	_, err := cm.Refresh(testComponent{id: "comp1"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	fComp, _ := cm.(*componentManager).isComponentFetched("comp2")
+	assert.Equal(t, comp2b.AsRepository("").Loc.String(), fComp.component.GetRepository().Loc.String())
+
+	// Then comp2 is refreshed from its new location
+	changed, err := cm.Refresh(testComponent{id: "comp2"})
+	if assert.Nil(t, err) {
+		assert.True(t, changed)
+		assert.Equal(t, "comp2b", cm.Model().(testModel).values["comp2"])
+		r, _ := cm.Resolved(testComponent{id: "comp2"})
+		assert.Equal(t, comp2b.AsRepository("").Loc.String(), r.Location.String())
+	}
+}
@@ -3,15 +3,19 @@ package componentizer
 import (
 	"fmt"
 	"net/url"
+	"regexp"
 	"strings"
 )
 
+//scpLikeLoc matches the scp-like syntax of ssh remotes, as "git@github.com:org/repo.git"
+var scpLikeLoc = regexp.MustCompile(`^([\w.-]+)@([\w.-]+):(.+)$`)
+
 type (
 	//Repository represents a component location
 	Repository struct {
 		// Loc holds the absolute location of the repository
 		Loc *url.URL
-		// The reference to the branch or tag to fetch. If not specified the default branch will be fetched
+		// The reference to the branch, tag or commit to fetch. If not specified the default branch will be fetched
 		Ref string
 		// The authentication parameters to use if repository is not publicly accessible
 		Authentication map[string]string
@@ -20,10 +24,11 @@ type (
 
 //CreateRepository creates a repository
 //	Parameters
-//		repo: the repository Url where to fetch the component
+//		repo: the repository Url where to fetch the component, the scp-like syntax "user@host:path"
+//            is also accepted for ssh remotes
 //		ref: the ref to fetch, if the ref is not specified then the default branch will be fetched
 func CreateRepository(loc string, ref string, auth map[string]string) (Repository, error) {
-	u, err := url.Parse(loc)
+	u, err := url.Parse(normalizeLoc(loc))
 	if err != nil {
 		return Repository{}, err
 	}
@@ -83,6 +88,16 @@ func (r *Repository) Merge(with Repository) {
 	}
 }
 
+//normalizeLoc converts a scp-like location into an ssh url
+func normalizeLoc(loc string) string {
+	if !strings.Contains(loc, "://") {
+		if m := scpLikeLoc.FindStringSubmatch(loc); m != nil {
+			return fmt.Sprintf("ssh://%s@%s/%s", m[1], m[2], strings.TrimPrefix(m[3], "/"))
+		}
+	}
+	return loc
+}
+
 func (r Repository) String() string {
 	if r.Loc != nil {
 		return fmt.Sprintf("%s@%s", r.Loc.String(), r.Ref)
@@ -33,3 +33,12 @@ func TestRepositoryZeroVal(t *testing.T) {
 	assert.Equal(t, "abc", r1.Authentication["key1"])
 	assert.Equal(t, "def", r1.Authentication["key2"])
 }
+
+func TestRepositoryScpLike(t *testing.T) {
+	r, e := CreateRepository("git@github.com:ekara-platform/repo1.git", "master", nil)
+	assert.Nil(t, e)
+	assert.Equal(t, "ssh", r.Loc.Scheme)
+	assert.Equal(t, "git", r.Loc.User.Username())
+	assert.Equal(t, "github.com", r.Loc.Host)
+	assert.Equal(t, "/ekara-platform/repo1.git", r.Loc.Path)
+}
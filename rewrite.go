@@ -0,0 +1,112 @@
+package componentizer
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type (
+	//RewriteRule replaces the beginning of the repository locations, like the
+	// "insteadOf" configuration of git. When several rules match a location
+	// the one with the longest prefix is applied.
+	RewriteRule struct {
+		// Prefix is the beginning of the locations to rewrite
+		Prefix string
+		// Replacement replaces the prefix into the rewritten locations
+		Replacement string
+	}
+
+	//FallbackRule defines the locations tried, in order, when a repository
+	// can't be fetched from its location
+	FallbackRule struct {
+		// Prefix is the beginning of the locations having fallbacks
+		Prefix string
+		// Replacements replace the prefix to build the fallback locations
+		Replacements []string
+	}
+
+	//locationFailure is the failure to fetch a repository from a location
+	locationFailure struct {
+		loc *url.URL
+		err error
+	}
+
+	//fallbackError reports the failure to fetch a repository from its location and
+	// from its fallback locations, it wraps the failure of the location
+	fallbackError struct {
+		failures []locationFailure
+	}
+)
+
+//rewriteLocation applies the matching rule with the longest prefix to the location
+func rewriteLocation(loc *url.URL, rules []RewriteRule) (*url.URL, error) {
+	s := loc.String()
+	var match *RewriteRule
+	for i, r := range rules {
+		if strings.HasPrefix(s, r.Prefix) && (match == nil || len(r.Prefix) > len(match.Prefix)) {
+			match = &rules[i]
+		}
+	}
+	if match == nil {
+		return loc, nil
+	}
+	return url.Parse(match.Replacement + strings.TrimPrefix(s, match.Prefix))
+}
+
+//fallbackLocations returns the fallback locations of the location, in the order
+// of the rules and of their replacements
+func fallbackLocations(loc *url.URL, rules []FallbackRule) ([]*url.URL, error) {
+	s := loc.String()
+	var res []*url.URL
+	for _, r := range rules {
+		if !strings.HasPrefix(s, r.Prefix) {
+			continue
+		}
+		for _, replacement := range r.Replacements {
+			u, err := url.Parse(replacement + strings.TrimPrefix(s, r.Prefix))
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, u)
+		}
+	}
+	return res, nil
+}
+
+//candidateLocations returns the locations to try, in order, to fetch the repository
+func (cm *componentManager) candidateLocations(r Repository) ([]*url.URL, error) {
+	primary, err := rewriteLocation(r.Loc, cm.rewrites)
+	if err != nil {
+		return nil, err
+	}
+	fallbacks, err := fallbackLocations(primary, cm.fallbacks)
+	if err != nil {
+		return nil, err
+	}
+	return append([]*url.URL{primary}, fallbacks...), nil
+}
+
+func (e *fallbackError) Error() string {
+	var b strings.Builder
+	for i, f := range e.failures {
+		if i == 0 {
+			fmt.Fprintf(&b, "primary %s: %s", f.loc, f.err)
+		} else {
+			fmt.Fprintf(&b, "; fallback %s: %s", f.loc, f.err)
+		}
+	}
+	return b.String()
+}
+
+func (e *fallbackError) Unwrap() error {
+	return e.failures[0].err
+}
+
+//isUnavailable returns true if the error is a failure to access the location, which
+// may not happen with the fallback locations
+func isUnavailable(err error) bool {
+	var ue *unavailableError
+	return errors.As(err, &ue) || errors.Is(err, errNotAvailableOffline)
+}
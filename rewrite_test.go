@@ -0,0 +1,97 @@
+package componentizer
+
+import (
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewriteLocation(t *testing.T) {
+	rules := []RewriteRule{
+		{Prefix: "https://github.com/", Replacement: "https://mirror.local/github/"},
+		{Prefix: "https://github.com/ekara-platform/", Replacement: "https://ekara.local/"},
+	}
+	u, _ := url.Parse("https://github.com/ekara-platform/repo1")
+	r, err := rewriteLocation(u, rules)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://ekara.local/repo1", r.String())
+
+	u, _ = url.Parse("https://github.com/other/repo1")
+	r, err = rewriteLocation(u, rules)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://mirror.local/github/other/repo1", r.String())
+
+	u, _ = url.Parse("https://gitlab.com/other/repo1")
+	r, err = rewriteLocation(u, rules)
+	assert.Nil(t, err)
+	assert.Equal(t, u, r)
+}
+
+func TestInitWithRewriteAndFallback(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", "value: main")
+	fixtures := "file://localhost" + tester.fixDir + "/"
+
+	repo, err := CreateRepository("https://github.com/ekara-platform/main", "", nil)
+	assert.Nil(t, err)
+	mainComp := testComponent{id: "main", repo: repo}
+	cm := CreateComponentManager(testLogger(), tester.compDir,
+		WithRewriteRules(RewriteRule{Prefix: "https://github.com/ekara-platform/", Replacement: "file://localhost/missing/"}),
+		WithFallbacks(FallbackRule{Prefix: "file://localhost/missing/", Replacements: []string{"file://localhost/other/", fixtures}}))
+
+	m, err := cm.Init(mainComp, tester.TemplateContext())
+	if assert.Nil(t, err) {
+		assert.Equal(t, "main", m.(testModel).values["main"])
+		res, ok := cm.Resolved(mainComp)
+		if assert.True(t, ok) {
+			assert.Equal(t, fixtures+"main", res.Location.String())
+			assert.Equal(t, repo, res.Repository)
+		}
+	}
+}
+
+func TestFallbackOnlyWhenUnavailable(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", "value: main")
+	fixtures := "file://localhost" + tester.fixDir + "/"
+
+	var mu sync.Mutex
+	var started []string
+	observer := WithObserver(ObserverFunc(func(e Event) {
+		if e.Type == EventFetchStarted {
+			mu.Lock()
+			defer mu.Unlock()
+			started = append(started, e.Url)
+		}
+	}))
+
+	// The reference would be missing from the fallback too
+	repo, err := CreateRepository(fixtures+"main", "missing", nil)
+	assert.Nil(t, err)
+	cm := CreateComponentManager(testLogger(), tester.compDir, observer,
+		WithFallbacks(FallbackRule{Prefix: fixtures, Replacements: []string{"file://localhost/other/"}}))
+	_, err = cm.Init(testComponent{id: "main", repo: repo}, tester.TemplateContext())
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{fixtures + "main"}, started)
+
+	// The failures of all the locations are reported
+	started = nil
+	repo, err = CreateRepository("file://localhost/missing/main", "", nil)
+	assert.Nil(t, err)
+	cm = CreateComponentManager(testLogger(), tester.compDir, observer,
+		WithFallbacks(FallbackRule{Prefix: "file://localhost/missing/", Replacements: []string{"file://localhost/other/"}}))
+	_, err = cm.Init(testComponent{id: "main", repo: repo}, tester.TemplateContext())
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "primary file://localhost/missing/main: ")
+		assert.Contains(t, err.Error(), "; fallback file://localhost/other/main: ")
+	}
+	assert.Equal(t, []string{"file://localhost/missing/main", "file://localhost/other/main"}, started)
+}
@@ -1,25 +1,129 @@
 package componentizer
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"net/url"
 	"os"
 	"path/filepath"
+	"sync"
 )
 
-//scmHandler is the common definition of all SCM handlers used to acces
-// to component repositories
-type scmHandler interface {
-	//Matches return true if a repository has already be fetched into the path and if its
-	// remote configuration is the same than the desired  one
-	Matches(u *url.URL, path string) bool
-	//Fetch fetches the repository content into the given path.
-	Fetch(u *url.URL, path string, auth map[string]string) error
-	//Update updates the repository content into the given path.
-	Update(path string, auth map[string]string) error
-	//Switch executes a checkout to the desired reference
-	Switch(path string, ref string) error
+type (
+	//ScmHandler is the common definition of all SCM handlers used to acces
+	// to component repositories
+	//
+	//The operations accessing the repository content must stop as soon as
+	// possible once the context is done.
+	ScmHandler interface {
+		//Matches return true if a repository has already be fetched into the path and if its
+		// remote configuration is the same than the desired  one
+		Matches(u *url.URL, path string) bool
+		//Fetch fetches the repository content into the given path.
+		Fetch(ctx context.Context, u *url.URL, path string, auth map[string]string) error
+		//Update updates the repository content into the given path and returns true if
+		// something has changed since the last fetch or update.
+		Update(ctx context.Context, path string, auth map[string]string) (bool, error)
+		//Switch executes a checkout to the desired reference
+		Switch(ctx context.Context, path string, ref string) error
+	}
+
+	//RefResolver is implemented by the SCM handlers able to resolve a reference, like
+	// a version range, into the concrete reference to switch to.
+	RefResolver interface {
+		//ResolveRef returns the reference to switch to for the repository fetched into the path
+		ResolveRef(path string, ref string) (string, error)
+	}
+
+	//RevisionReader is implemented by the SCM handlers able to identify the exact revision
+	// of a fetched repository. The returned revision can be used as reference to switch
+	// back to the same content.
+	RevisionReader interface {
+		//Revision returns the revision of the repository fetched into the path
+		Revision(path string) (string, error)
+	}
+
+	//RemoteInspector is implemented by the SCM handlers able to inspect a remote repository
+	// without fetching it, as "git ls-remote" does.
+	RemoteInspector interface {
+		//RemoteRevision returns the reference to switch to, as a RefResolver would resolve it,
+		// and the revision it points to into the remote repository. The revision is empty
+		// if it can't be determined without fetching the repository.
+		RemoteRevision(ctx context.Context, u *url.URL, ref string, auth map[string]string) (string, string, error)
+		//LocalRevision returns the revision, as returned by a RevisionReader, corresponding
+		// to a remote revision available into the repository fetched into the path
+		LocalRevision(path string, revision string) (string, bool)
+	}
+
+	//CacheAware is implemented by the SCM handlers able to share the repository data
+	// between several work directories.
+	CacheAware interface {
+		//WithCache returns an handler storing the repository data into the cache directory
+		WithCache(dir string) ScmHandler
+	}
+
+	//ScmHandlerFactory creates the SCM handler able to access the repository
+	// located at the given url
+	ScmHandlerFactory func(l Logger, u *url.URL) (ScmHandler, error)
+
+	//fetchSettings holds the settings applied when fetching a component
+	fetchSettings struct {
+		// handlers overrides the globally registered SCM handler factories
+		handlers map[string]ScmHandlerFactory
+		// credentials are consulted if the repository has no authentication
+		credentials []CredentialProvider
+		// revision is the locked revision to switch to, if any
+		revision string
+		// offline prevents any access to remote repositories
+		offline bool
+		// cacheDir is the directory shared between work directories, if any
+		cacheDir string
+		// loc replaces the location of the repository, if any
+		loc *url.URL
+		// workTree copies the working tree of the local git repositories instead
+		// of cloning them, their uncommitted changes being then picked up
+		workTree bool
+	}
+)
+
+var (
+	scmHandlersMu sync.RWMutex
+	scmHandlers   = map[string]ScmHandlerFactory{
+		SchemeFile:    newLocalScmHandler,
+		SchemeGits:    newGitScmHandler,
+		SchemeSsh:     newGitScmHandler,
+		SchemeHttp:    newRemoteScmHandler,
+		SchemeHttps:   newRemoteScmHandler,
+		SchemeSvn:     newSvnScmHandler,
+		SchemeSvnFile: newSvnScmHandler,
+		SchemeSvnSsh:  newSvnScmHandler,
+	}
+)
+
+//RegisterScmHandler registers, for all the component managers, the factory used to
+// create SCM handlers for repositories located with the given url scheme.
+//
+//Registering a factory for an already registered scheme replaces the previous one.
+func RegisterScmHandler(scheme string, factory ScmHandlerFactory) {
+	scmHandlersMu.Lock()
+	defer scmHandlersMu.Unlock()
+	if factory == nil {
+		delete(scmHandlers, scheme)
+		return
+	}
+	scmHandlers[scheme] = factory
+}
+
+//lookupScmHandler returns the factory registered for the given scheme, the
+// overrides taking precedence over the globally registered factories
+func lookupScmHandler(scheme string, overrides map[string]ScmHandlerFactory) (ScmHandlerFactory, bool) {
+	if f, ok := overrides[scheme]; ok {
+		return f, true
+	}
+	scmHandlersMu.RLock()
+	defer scmHandlersMu.RUnlock()
+	f, ok := scmHandlers[scheme]
+	return f, ok
 }
 
 //Handler allows to fetch a component.
@@ -27,56 +131,218 @@ type scmHandler interface {
 //If the component repository has already been fetched and if it matches
 // then it will be updated, if not it will be fetched.
 //
-type Handler func() (fetchedComponent, error)
+//If the fetch of the component from scratch fails, including when the context is
+// done, then the partially fetched content is removed.
+type Handler func(ctx context.Context) (fetchedComponent, error)
+
+//GetScmHandler returns an handler able to fetch a component
+func GetScmHandler(l Logger, dir string, c Component) (Handler, error) {
+	return getScmHandler(context.Background(), l, dir, c, fetchSettings{})
+}
+
+func getScmHandler(ctx context.Context, l Logger, dir string, c Component, s fetchSettings) (Handler, error) {
+	scm, loc, auth, err := createScmHandler(ctx, l, c, s)
+	if err != nil {
+		return nil, err
+	}
+	return fetchThroughSCM(c, scm, loc, dir, auth, s), nil
+}
 
-//GetHandler returns an handler able to fetch a component
-func GetScmHandler(l *log.Logger, dir string, c Component) (Handler, error) {
+//createScmHandler returns the SCM handler able to access the repository of the component
+// along with its effective location and authentication, the credential providers being
+// only consulted for the remote locations when online
+func createScmHandler(ctx context.Context, l Logger, c Component, s fetchSettings) (ScmHandler, *url.URL, map[string]string, error) {
 	loc := c.GetRepository().Loc
-	switch loc.Scheme {
-	case SchemeFile:
-		// Assume local file is still a git repository for now
-		return fetchThroughSCM(c, GitScmHandler{Logger: l}, loc, dir), nil
-	case SchemeGits, SchemeHttp, SchemeHttps:
-		return fetchThroughSCM(c, GitScmHandler{Logger: l}, loc, dir), nil
-	default:
-		return nil, fmt.Errorf("unsupported SCM: %s", loc.String())
+	if s.loc != nil {
+		loc = s.loc
+	}
+	factory, ok := lookupScmHandler(loc.Scheme, s.handlers)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("unsupported SCM: %s", loc.String())
+	}
+	scm, err := factory(l, loc)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if _, ok := scm.(GitScmHandler); ok && s.workTree && loc.Scheme == SchemeFile {
+		scm = workTreeScmHandler{FileScmHandler{Logger: l}}
+	}
+	if ca, ok := scm.(CacheAware); ok && s.cacheDir != "" {
+		scm = ca.WithCache(s.cacheDir)
 	}
+	providers := s.credentials
+	if s.offline || isLocalLocation(loc) {
+		providers = nil
+	}
+	auth, err := resolveCredentials(ctx, loc, c.GetRepository().Authentication, providers)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return scm, loc, auth, nil
+}
+
+//offlineMatcher is implemented by the SCM handlers which can't update their content,
+// then never matching it online, but able to recognize it to reuse it offline
+type offlineMatcher interface {
+	matchesOffline(u *url.URL, path string) bool
+}
+
+//reusableOffline returns true if the content of the path can be used offline
+// as the content of the location
+func reusableOffline(scm ScmHandler, u *url.URL, path string) bool {
+	if om, ok := scm.(offlineMatcher); ok {
+		return om.matchesOffline(u, path)
+	}
+	return scm.Matches(u, path)
 }
 
-func fetchThroughSCM(c Component, scm scmHandler, u *url.URL, dir string) func() (fetchedComponent, error) {
-	return func() (fetchedComponent, error) {
+//remoteSwitcher is implemented by the SCM handlers whose Switch accesses the remote
+// repository, offline they can only reuse the content already on the reference
+type remoteSwitcher interface {
+	switchedTo(path string, ref string) bool
+}
+
+//authSwitcher is implemented by the SCM handlers whose switch to a reference
+// requires the authentication of the repository
+type authSwitcher interface {
+	switchWithAuth(ctx context.Context, path string, ref string, auth map[string]string) error
+}
+
+//switchTo switches the repository fetched into the path to the reference, without
+// accessing the remote repository when offline
+func switchTo(ctx context.Context, c Component, scm ScmHandler, u *url.URL, path string, ref string, auth map[string]string, s fetchSettings) error {
+	if rs, ok := scm.(remoteSwitcher); ok && s.offline && !isLocalLocation(u) {
+		if !rs.switchedTo(path, ref) {
+			return fmt.Errorf("component %s has not been fetched at %s: %w", c.ComponentId(), ref, errNotAvailableOffline)
+		}
+		return nil
+	}
+	if as, ok := scm.(authSwitcher); ok {
+		return as.switchWithAuth(ctx, path, ref, auth)
+	}
+	return scm.Switch(ctx, path, ref)
+}
+
+//isLocalLocation returns true if the location can be accessed without any network
+func isLocalLocation(u *url.URL) bool {
+	return u.Scheme == SchemeFile || u.Scheme == SchemeSvnFile
+}
+
+func fetchThroughSCM(c Component, scm ScmHandler, u *url.URL, dir string, auth map[string]string, s fetchSettings) Handler {
+	return func(ctx context.Context) (fetchedComponent, error) {
 		fc := fetchedComponent{
-			id: c.ComponentId(),
+			id:       c.ComponentId(),
+			location: u,
 		}
 		cPath := filepath.Join(dir, c.ComponentId())
 		fc.rootPath = cPath
-		if _, err := os.Stat(cPath); err == nil {
+		if err := canceled(ctx, "fetch of component "+c.ComponentId()); err != nil {
+			return fc, err
+		}
+		if s.offline && !isLocalLocation(u) {
+			// Existing repositories are used as-is
+			if _, err := os.Stat(cPath); err != nil || !reusableOffline(scm, u, cPath) {
+				return fc, fmt.Errorf("component %s has not been fetched: %w", c.ComponentId(), errNotAvailableOffline)
+			}
+		} else if _, err := os.Stat(cPath); err == nil {
 			if scm.Matches(u, cPath) {
-				err := scm.Update(cPath, c.GetRepository().Authentication)
+				changed, err := scm.Update(ctx, cPath, auth)
 				if err != nil {
-					return fc, err
+					return fc, unavailable(ctx, c, err)
 				}
+				fc.changed = changed
 			} else {
 				err := os.RemoveAll(cPath)
 				if err != nil {
 					return fc, err
 				}
-				err = scm.Fetch(u, cPath, c.GetRepository().Authentication)
+				err = scm.Fetch(ctx, u, cPath, auth)
 				if err != nil {
-					return fc, err
+					return fc, removePartial(ctx, c, cPath, err)
 				}
+				fc.changed = true
+				fc.fresh = true
 			}
 		} else {
-			err := scm.Fetch(u, cPath, c.GetRepository().Authentication)
+			err := scm.Fetch(ctx, u, cPath, auth)
+			if err != nil {
+				return fc, removePartial(ctx, c, cPath, err)
+			}
+			fc.changed = true
+			fc.fresh = true
+		}
+		if s.revision != "" {
+			// Switch to the locked revision
+			err := switchTo(ctx, c, scm, u, cPath, s.revision, auth, s)
+			if err != nil {
+				if ctx.Err() != nil {
+					return fc, interrupted(ctx, c, err)
+				}
+				return fc, &LockedRevisionError{Id: c.ComponentId(), Revision: s.revision, Err: err}
+			}
+			fc.ref = s.revision
+			fc.revision = s.revision
+			return fc, nil
+		}
+
+		ref := c.GetRepository().Ref
+		if r, ok := scm.(RefResolver); ok {
+			var err error
+			ref, err = r.ResolveRef(cPath, ref)
 			if err != nil {
 				return fc, err
 			}
 		}
-		err := scm.Switch(cPath, c.GetRepository().Ref)
+		fc.ref = ref
+		err := switchTo(ctx, c, scm, u, cPath, ref, auth, s)
 		if err != nil {
-			return fc, err
+			return fc, interrupted(ctx, c, err)
+		}
+		if r, ok := scm.(RevisionReader); ok {
+			fc.revision, err = r.Revision(cPath)
+			if err != nil {
+				return fc, err
+			}
 		}
 
 		return fc, nil
 	}
 }
+
+//interrupted returns an error wrapping the context error if the context is done,
+// the SCM handlers not always reporting it, or the given error otherwise
+func interrupted(ctx context.Context, c Component, err error) error {
+	if cErr := canceled(ctx, "fetch of component "+c.ComponentId()); cErr != nil {
+		return cErr
+	}
+	return err
+}
+
+//unavailableError is a failure to access the location of a repository
+type unavailableError struct {
+	err error
+}
+
+func (e *unavailableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *unavailableError) Unwrap() error {
+	return e.err
+}
+
+//unavailable returns an error wrapping the context error if the context is done,
+// or the given error marked as a failure to access the location otherwise
+func unavailable(ctx context.Context, c Component, err error) error {
+	if cErr := canceled(ctx, "fetch of component "+c.ComponentId()); cErr != nil {
+		return cErr
+	}
+	return &unavailableError{err: err}
+}
+
+//removePartial removes the content partially fetched, which would be taken otherwise
+// for a complete repository by the next fetch
+func removePartial(ctx context.Context, c Component, path string, err error) error {
+	os.RemoveAll(path)
+	return unavailable(ctx, c, err)
+}
@@ -0,0 +1,137 @@
+package componentizer
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type dummyScmHandler struct{}
+
+func (dummyScmHandler) Matches(u *url.URL, path string) bool { return false }
+func (dummyScmHandler) Fetch(ctx context.Context, u *url.URL, path string, auth map[string]string) error {
+	return nil
+}
+func (dummyScmHandler) Update(ctx context.Context, path string, auth map[string]string) (bool, error) {
+	return false, nil
+}
+func (dummyScmHandler) Switch(ctx context.Context, path string, ref string) error { return nil }
+
+func newDummyScmHandler(l Logger, u *url.URL) (ScmHandler, error) {
+	return dummyScmHandler{}, nil
+}
+
+func TestRegisterScmHandler(t *testing.T) {
+	RegisterScmHandler("dummy", newDummyScmHandler)
+	defer RegisterScmHandler("dummy", nil)
+
+	checkScm(t, "dummy:///blablabla/my_repo", SCMType("dummy"))
+
+	f, ok := lookupScmHandler("dummy", nil)
+	if assert.True(t, ok) {
+		h, err := f(testLogger(), nil)
+		assert.Nil(t, err)
+		assert.IsType(t, dummyScmHandler{}, h)
+	}
+}
+
+func TestScmHandlerOverride(t *testing.T) {
+	overrides := map[string]ScmHandlerFactory{SchemeHttps: newDummyScmHandler}
+	u, err := url.Parse("https://github.com/GroupePSA/componentizer")
+	assert.Nil(t, err)
+
+	f, ok := lookupScmHandler(SchemeHttps, overrides)
+	if assert.True(t, ok) {
+		h, err := f(testLogger(), u)
+		assert.Nil(t, err)
+		assert.IsType(t, dummyScmHandler{}, h)
+	}
+
+	f, ok = lookupScmHandler(SchemeHttps, nil)
+	if assert.True(t, ok) {
+		h, err := f(testLogger(), u)
+		assert.Nil(t, err)
+		assert.IsType(t, GitScmHandler{}, h)
+	}
+
+	// The reported type of SCM follows the override
+	scm, err := resolveSCMType(*u, overrides)
+	assert.Nil(t, err)
+	assert.Equal(t, SCMType(SchemeHttps), scm)
+	scm, err = resolveSCMType(*u, map[string]ScmHandlerFactory{SchemeHttps: newSvnScmHandler})
+	assert.Nil(t, err)
+	assert.Equal(t, SvnScm, scm)
+	scm, err = resolveSCMType(*u, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, GitScm, scm)
+}
+
+func TestLocalScmHandlerDetection(t *testing.T) {
+	abs, err := filepath.Abs(filepath.Join("testdata", "dummy_org", "dummy_repo"))
+	assert.Nil(t, err)
+	u, err := url.Parse("file://" + abs)
+	assert.Nil(t, err)
+
+	h, err := newLocalScmHandler(testLogger(), u)
+	assert.Nil(t, err)
+	assert.IsType(t, FileScmHandler{}, h)
+
+	dest, err := ioutil.TempDir("", "componentizer_file")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dest)
+	cPath := filepath.Join(dest, "dummy")
+	assert.Nil(t, h.Fetch(context.Background(), u, cPath, nil))
+	assert.FileExists(t, filepath.Join(cPath, "DO_NOT_DELETE"))
+}
+
+//cancelingScmHandler cancels the fetch after having written a partial content
+type cancelingScmHandler struct {
+	dummyScmHandler
+	cancel context.CancelFunc
+}
+
+func (h cancelingScmHandler) Fetch(ctx context.Context, u *url.URL, path string, auth map[string]string) error {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+	h.cancel()
+	return errors.New("interrupted")
+}
+
+func TestFetchCanceledRemovesPartialContent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "componentizer_cancel")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	repo, err := CreateRepository("https://github.com/ekara-platform/canceled", "", nil)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h := cancelingScmHandler{cancel: cancel}
+
+	_, err = fetchThroughSCM(testComponent{id: "canceled", repo: repo}, h, repo.Loc, dir, nil, fetchSettings{})(ctx)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.False(t, DirExist(filepath.Join(dir, "canceled")))
+}
+
+func TestFetchFailureRemovesPartialContent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "componentizer_failure")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	repo, err := CreateRepository("https://github.com/ekara-platform/failed", "", nil)
+	assert.Nil(t, err)
+	h := cancelingScmHandler{cancel: func() {}}
+
+	_, err = fetchThroughSCM(testComponent{id: "failed", repo: repo}, h, repo.Loc, dir, nil, fetchSettings{})(context.Background())
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "interrupted", err.Error())
+	}
+	assert.False(t, DirExist(filepath.Join(dir, "failed")))
+}
@@ -0,0 +1,278 @@
+package componentizer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	//version is a semantic version, eventually partial, as "1.4" or "v2.0.3-rc.1"
+	version struct {
+		// numbers holds the major, minor and patch numbers
+		numbers [3]int
+		// parts is the number of numbers specified, the others being wildcards
+		parts int
+		pre   []string
+	}
+
+	//comparator is an operation applied on a version
+	comparator struct {
+		op string
+		v  version
+	}
+
+	//versionConstraint is a disjunction of conjunctions of comparators
+	versionConstraint [][]comparator
+)
+
+var (
+	versionRegexp        = regexp.MustCompile(`^v?(\d+|[xX*])(?:\.(\d+|[xX*]))?(?:\.(\d+|[xX*]))?(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+	comparatorRegexp     = regexp.MustCompile(`^(\^|~>?|>=|<=|>|<|=|!=)?\s*(\S+)$`)
+	constraintHintRegexp = regexp.MustCompile(`^[\^~><=!*]|\|\||\d\.[xX*](\.|$)`)
+)
+
+//isVersionConstraint returns true if the reference is a version range rather than a tag or branch name
+func isVersionConstraint(ref string) bool {
+	if !constraintHintRegexp.MatchString(strings.TrimSpace(ref)) {
+		return false
+	}
+	_, err := parseConstraint(ref)
+	return err == nil
+}
+
+//parseVersion parses a semantic version, missing or wildcard numbers are allowed
+func parseVersion(s string) (version, error) {
+	m := versionRegexp.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return version{}, fmt.Errorf("invalid version %s", s)
+	}
+	v := version{}
+	for i := 0; i < 3; i++ {
+		n := m[i+1]
+		if n == "" || n == "x" || n == "X" || n == "*" {
+			break
+		}
+		v.numbers[i], _ = strconv.Atoi(n)
+		v.parts++
+	}
+	if m[4] != "" {
+		v.pre = strings.Split(m[4], ".")
+	}
+	return v, nil
+}
+
+func (v version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.numbers[0], v.numbers[1], v.numbers[2])
+	if len(v.pre) > 0 {
+		s = s + "-" + strings.Join(v.pre, ".")
+	}
+	return s
+}
+
+//compare returns -1, 0 or 1 if the version is lower, equal or greater than the other one
+func (v version) compare(o version) int {
+	for i := 0; i < 3; i++ {
+		if v.numbers[i] != o.numbers[i] {
+			if v.numbers[i] < o.numbers[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	// A pre-release version has a lower precedence than the normal version
+	switch {
+	case len(v.pre) == 0 && len(o.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(o.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.pre) && i < len(o.pre); i++ {
+		if c := comparePreIdentifier(v.pre[i], o.pre[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(v.pre) < len(o.pre):
+		return -1
+	case len(v.pre) > len(o.pre):
+		return 1
+	}
+	return 0
+}
+
+func comparePreIdentifier(a, b string) int {
+	na, ea := strconv.Atoi(a)
+	nb, eb := strconv.Atoi(b)
+	switch {
+	case ea == nil && eb == nil:
+		if na == nb {
+			return 0
+		}
+		if na < nb {
+			return -1
+		}
+		return 1
+	case ea == nil:
+		// Numeric identifiers have a lower precedence
+		return -1
+	case eb == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+//bump returns the lowest version greater than all the versions matching the
+// specified numbers of the partial version
+func (v version) bump(part int) version {
+	res := version{parts: 3}
+	for i := 0; i < part; i++ {
+		res.numbers[i] = v.numbers[i]
+	}
+	res.numbers[part] = v.numbers[part] + 1
+	return res
+}
+
+//parseConstraint parses a version range as "^1.4", "~2.0.3", ">=1.0 <2.0" or "1.x || 2.x"
+func parseConstraint(s string) (versionConstraint, error) {
+	var res versionConstraint
+	for _, group := range strings.Split(s, "||") {
+		var and []comparator
+		for _, term := range strings.FieldsFunc(group, func(r rune) bool { return r == ',' || r == ' ' }) {
+			comps, err := parseComparator(term)
+			if err != nil {
+				return nil, err
+			}
+			and = append(and, comps...)
+		}
+		if len(and) == 0 {
+			return nil, fmt.Errorf("invalid version constraint %s", s)
+		}
+		res = append(res, and)
+	}
+	return res, nil
+}
+
+//parseComparator converts a single term of a version range into basic comparators
+func parseComparator(term string) ([]comparator, error) {
+	m := comparatorRegexp.FindStringSubmatch(term)
+	if m == nil {
+		return nil, fmt.Errorf("invalid version constraint %s", term)
+	}
+	v, err := parseVersion(m[2])
+	if err != nil {
+		return nil, err
+	}
+	op := m[1]
+
+	if v.parts == 0 {
+		// Wildcard, everything matches except for exclusions
+		if op == "" || op == "=" || op == ">=" || op == "<=" {
+			return []comparator{{op: ">=", v: version{}}}, nil
+		}
+		return nil, fmt.Errorf("invalid version constraint %s", term)
+	}
+
+	switch op {
+	case "^":
+		// Changes that do not modify the left-most non-zero number
+		part := 0
+		for part < v.parts-1 && v.numbers[part] == 0 {
+			part++
+		}
+		return []comparator{{op: ">=", v: v}, {op: "<", v: v.bump(part)}}, nil
+	case "~", "~>":
+		// Patch level changes if the minor is specified, minor level changes otherwise
+		part := 1
+		if v.parts == 1 {
+			part = 0
+		}
+		return []comparator{{op: ">=", v: v}, {op: "<", v: v.bump(part)}}, nil
+	case "", "=":
+		if v.parts < 3 {
+			return []comparator{{op: ">=", v: v}, {op: "<", v: v.bump(v.parts - 1)}}, nil
+		}
+		return []comparator{{op: "=", v: v}}, nil
+	case "!=":
+		return []comparator{{op: "!=", v: v}}, nil
+	case ">":
+		if v.parts < 3 {
+			return []comparator{{op: ">=", v: v.bump(v.parts - 1)}}, nil
+		}
+	case "<=":
+		if v.parts < 3 {
+			return []comparator{{op: "<", v: v.bump(v.parts - 1)}}, nil
+		}
+	}
+	return []comparator{{op: op, v: v}}, nil
+}
+
+func (c comparator) matches(v version) bool {
+	r := v.compare(c.v)
+	switch c.op {
+	case "=":
+		return r == 0
+	case "!=":
+		return r != 0
+	case ">":
+		return r > 0
+	case ">=":
+		return r >= 0
+	case "<":
+		return r < 0
+	case "<=":
+		return r <= 0
+	}
+	return false
+}
+
+//matches returns true if the version satisfies the constraint, pre-release versions
+// only satisfy comparators explicitly targeting a pre-release of the same version
+func (vc versionConstraint) matches(v version) bool {
+	for _, and := range vc {
+		ok := true
+		preAllowed := len(v.pre) == 0
+		for _, c := range and {
+			if !c.matches(v) {
+				ok = false
+				break
+			}
+			if len(c.v.pre) > 0 && c.v.numbers == v.numbers {
+				preAllowed = true
+			}
+		}
+		if ok && preAllowed {
+			return true
+		}
+	}
+	return false
+}
+
+//highestMatching returns the name with the highest version satisfying the constraint
+func (vc versionConstraint) highestMatching(names []string) (string, bool) {
+	type candidate struct {
+		name string
+		v    version
+	}
+	var candidates []candidate
+	for _, name := range names {
+		v, err := parseVersion(name)
+		if err != nil || v.parts < 3 {
+			continue
+		}
+		if vc.matches(v) {
+			candidates = append(candidates, candidate{name: name, v: v})
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].v.compare(candidates[j].v) > 0
+	})
+	return candidates[0].name, true
+}
@@ -0,0 +1,71 @@
+package componentizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionConstraintDetection(t *testing.T) {
+	for _, ref := range []string{"^1.4", "~2.0.3", ">=1.0 <2.0", "1.x", "1.2.*", "*", "1.x || 2.x"} {
+		assert.True(t, isVersionConstraint(ref), ref)
+	}
+	for _, ref := range []string{"", "master", "v1.4.0", "1.4", "fix", "x", "refs/tags/v1.0.0", "feature/1.x"} {
+		assert.False(t, isVersionConstraint(ref), ref)
+	}
+}
+
+func TestVersionConstraintHighestMatching(t *testing.T) {
+	tags := []string{"v1.3.9", "v1.4.0", "v1.4.2", "v1.5.0-rc.1", "v1.5.0", "v2.0.3", "v2.0.5", "v2.1.0", "0.2.3", "0.2.9", "0.3.0", "latest"}
+	cases := map[string]string{
+		"^1.4":          "v1.5.0",
+		"~1.4":          "v1.4.2",
+		"~2.0.3":        "v2.0.5",
+		"^0.2.3":        "0.2.9",
+		">=1.4 <1.5":    "v1.4.2",
+		">1.4.0, <=1.5": "v1.5.0",
+		"1.x":           "v1.5.0",
+		"2.0.x || 0.x":  "v2.0.5",
+		"*":             "v2.1.0",
+		"=2.0.3":        "v2.0.3",
+		"^1.5.0-rc.0":   "v1.5.0",
+		"<1.5.0-rc.2":   "v1.5.0-rc.1",
+	}
+	for ref, expected := range cases {
+		c, err := parseConstraint(ref)
+		if assert.Nil(t, err, ref) {
+			tag, ok := c.highestMatching(tags)
+			assert.True(t, ok, ref)
+			assert.Equal(t, expected, tag, ref)
+		}
+	}
+
+	c, err := parseConstraint("^3.0")
+	assert.Nil(t, err)
+	_, ok := c.highestMatching(tags)
+	assert.False(t, ok)
+}
+
+func TestInitWithVersionRange(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", "value: v1.4.0")
+	main.Tag("v1.4.0")
+	main.WriteCommit("ekara.yaml", "value: v1.4.1")
+	main.Tag("v1.4.1")
+	main.WriteCommit("ekara.yaml", "value: v2.0.0")
+	main.Tag("v2.0.0")
+
+	mainComp := testComponent{id: "main", repo: main.AsRepository("^1.4")}
+	err := tester.Init(mainComp)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "v1.4.1", tester.Model().(testModel).values["main"])
+		res, ok := tester.ComponentManager().Resolved(mainComp)
+		if assert.True(t, ok) {
+			assert.Equal(t, "v1.4.1", res.Ref)
+			assert.Equal(t, "^1.4", res.Repository.Ref)
+		}
+	}
+}
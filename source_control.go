@@ -13,6 +13,10 @@ type (
 const (
 	//GitScm type of GIT source control management system
 	GitScm SCMType = SCMType(SchemeGits)
+	//FileScm type of plain directories, without any source control management system
+	FileScm SCMType = SCMType(SchemeFile)
+	//ArchiveScm type of components distributed as archives
+	ArchiveScm SCMType = "archive"
 	//SvnScm type of SVN source control management system
 	SvnScm SCMType = SCMType(SchemeSvn)
 	//UnknownScm represents an unknown source control management system
@@ -22,21 +26,42 @@ const (
 	SchemeFile string = "file"
 	//SchemeGits  scheme for Git
 	SchemeGits string = "git"
+	//SchemeSsh  scheme for Git over ssh
+	SchemeSsh string = "ssh"
 	//SchemeSvn  scheme for svn
 	SchemeSvn string = "svn"
+	//SchemeSvnFile  scheme for svn on a local repository
+	SchemeSvnFile string = "svn+file"
+	//SchemeSvnSsh  scheme for svn over ssh
+	SchemeSvnSsh string = "svn+ssh"
 	//SchemeHttp  scheme for http
 	SchemeHttp string = "http"
 	//SchemeHttps  scheme for https
 	SchemeHttps string = "https"
 )
 
-func resolveSCMType(loc url.URL) (SCMType, error) {
-	switch loc.Scheme {
-	case SchemeFile, SchemeGits, SchemeHttp, SchemeHttps:
-		// TODO: for now assume git on local directories, later try to detect
+//resolveSCMType returns the type of the SCM handler created for the location by the
+// factory registered for its scheme, the overrides taking precedence over the globally
+// registered factories
+func resolveSCMType(loc url.URL, overrides map[string]ScmHandlerFactory) (SCMType, error) {
+	factory, ok := lookupScmHandler(loc.Scheme, overrides)
+	if !ok {
+		return UnknownScm, errors.New("unknown fetch protocol: " + loc.Scheme)
+	}
+	h, err := factory(nopLogger{}, &loc)
+	if err != nil {
+		return UnknownScm, err
+	}
+	switch h.(type) {
+	case GitScmHandler:
 		return GitScm, nil
-	case SchemeSvn:
+	case FileScmHandler:
+		return FileScm, nil
+	case ArchiveScmHandler:
+		return ArchiveScm, nil
+	case *SvnScmHandler:
 		return SvnScm, nil
 	}
-	return UnknownScm, errors.New("unknown fetch protocol: " + loc.Scheme)
+	// The handlers provided by users are their own type of SCM
+	return SCMType(loc.Scheme), nil
 }
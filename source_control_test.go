@@ -1,10 +1,14 @@
 package componentizer
 
 import (
+	"io/ioutil"
 	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4"
 )
 
 func TestScmGitOnFile(t *testing.T) {
@@ -36,7 +40,7 @@ func checkScm(t *testing.T, rawurl string, wanted SCMType) {
 	u, e := url.Parse(rawurl)
 	assert.Nil(t, e)
 
-	s, e := resolveSCMType(*u)
+	s, e := resolveSCMType(*u, nil)
 	if assert.Nil(t, e) {
 		assert.Equal(t, s, wanted)
 	}
@@ -47,8 +51,33 @@ func TestScmUnknown(t *testing.T) {
 	u, e := url.Parse(us)
 	assert.Nil(t, e)
 
-	s, e := resolveSCMType(*u)
+	s, e := resolveSCMType(*u, nil)
 	if assert.NotNil(t, e) {
 		assert.Equal(t, s, UnknownScm)
 	}
 }
+
+func TestScmSvnOnSvnFile(t *testing.T) {
+	us := "svn+file:///blablabla"
+	checkScm(t, us, SvnScm)
+}
+
+func TestScmFileOnPlainDirectory(t *testing.T) {
+	abs, e := filepath.Abs(filepath.Join("testdata", "dummy_org", "dummy_repo"))
+	assert.Nil(t, e)
+	checkScm(t, "file://"+abs, FileScm)
+}
+
+func TestScmGitOnLocalRepository(t *testing.T) {
+	dir, e := ioutil.TempDir("", "componentizer_scm")
+	assert.Nil(t, e)
+	defer os.RemoveAll(dir)
+	_, e = git.PlainInit(dir, false)
+	assert.Nil(t, e)
+	checkScm(t, "file://"+dir, GitScm)
+}
+
+func TestScmArchiveOnHttps(t *testing.T) {
+	us := "https:///blablabla/my_component-1.0.tar.gz"
+	checkScm(t, us, ArchiveScm)
+}
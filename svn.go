@@ -0,0 +1,329 @@
+package componentizer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+const (
+	svnTrunk    = "trunk"
+	svnTags     = "tags"
+	svnBranches = "branches"
+)
+
+//svnPasswordFromStdin is the first svn version able to read the password from
+// the standard input
+var svnPasswordFromStdin = version{numbers: [3]int{1, 10, 0}, parts: 2}
+
+var (
+	// svnVersions holds the versions of the svn clients already checked, by path
+	svnVersions   = map[string]version{}
+	svnVersionsMu sync.Mutex
+)
+
+//SvnScmHandler Represents the scm connector allowing to fetch SVN repositories.
+//
+//The repository location is expected to be the root of a standard SVN layout
+// (trunk, tags and branches), the reference is then resolved as a tag first and
+// as a branch otherwise. If no reference is specified the trunk is used, or the
+// location itself if it doesn't have any trunk.
+//
+//A reference can target a specific revision using the peg revision syntax, as
+// "tags/1.0@42".
+//
+//The handler relies on the "svn" command line client which must be available
+// into the path, authenticating with a password requires svn 1.10 or later.
+type SvnScmHandler struct {
+	Logger Logger
+}
+
+func newSvnScmHandler(l Logger, u *url.URL) (ScmHandler, error) {
+	return &SvnScmHandler{Logger: l}, nil
+}
+
+//Matches implements "github.com/ekara-platform/engine/component/scm.scmHandler
+func (svnScm *SvnScmHandler) Matches(u *url.URL, path string) bool {
+	out, err := svnScm.run(context.Background(), nil, "info", "--show-item", "url", path)
+	if err != nil {
+		return false
+	}
+	current := strings.TrimSpace(out)
+	root := strings.TrimSuffix(svnURL(u), "/")
+	return current == root || strings.HasPrefix(current, root+"/")
+}
+
+//Fetch implements "github.com/ekara-platform/engine/component/scm.scmHandler
+func (svnScm *SvnScmHandler) Fetch(ctx context.Context, u *url.URL, path string, auth map[string]string) error {
+	source := svnURL(u)
+	svnScm.Logger.Debug("checking out SVN repository", UrlField(source), PathField(path))
+	// Only the root is checked out here, the content will be
+	// retrieved when switching to the desired location
+	_, err := svnScm.run(ctx, auth, "checkout", "--depth", "empty", source, path)
+	if err != nil {
+		return errors.New("unable to checkout svn repository " + source + ": " + err.Error())
+	}
+	return nil
+}
+
+//Update implements "github.com/ekara-platform/engine/component/scm.scmHandler
+func (svnScm *SvnScmHandler) Update(ctx context.Context, path string, auth map[string]string) (bool, error) {
+	before, err := svnScm.run(ctx, nil, "info", "--show-item", "last-changed-revision", path)
+	if err != nil {
+		return false, errors.New("unable to access svn working copy " + path + ": " + err.Error())
+	}
+	svnScm.Logger.Debug("updating SVN working copy", PathField(path))
+	_, err = svnScm.run(ctx, auth, "update", path)
+	if err != nil {
+		return false, errors.New("unable to update svn working copy " + path + ": " + err.Error())
+	}
+	after, err := svnScm.run(ctx, nil, "info", "--show-item", "last-changed-revision", path)
+	if err != nil {
+		return false, errors.New("unable to access svn working copy " + path + ": " + err.Error())
+	}
+	return strings.TrimSpace(before) != strings.TrimSpace(after), nil
+}
+
+//Switch implements "github.com/ekara-platform/engine/component/scm.scmHandler
+//
+//The repository is accessed without authentication, see switchWithAuth.
+func (svnScm *SvnScmHandler) Switch(ctx context.Context, path string, ref string) error {
+	return svnScm.switchWithAuth(ctx, path, ref, nil)
+}
+
+//switchWithAuth implements authSwitcher, SVN accessing the repository to resolve
+// the reference and to switch to it
+func (svnScm *SvnScmHandler) switchWithAuth(ctx context.Context, path string, ref string, auth map[string]string) error {
+	out, err := svnScm.run(ctx, nil, "info", "--show-item", "repos-root-url", path)
+	if err != nil {
+		return errors.New("unable to access svn working copy " + path + ": " + err.Error())
+	}
+	reposRoot := strings.TrimSpace(out)
+	out, err = svnScm.run(ctx, nil, "info", "--show-item", "url", path)
+	if err != nil {
+		return errors.New("unable to access svn working copy " + path + ": " + err.Error())
+	}
+	root := componentRoot(strings.TrimSpace(out), reposRoot)
+
+	name, peg := ref, ""
+	if idx := strings.LastIndex(ref, "@"); idx != -1 {
+		name, peg = ref[:idx], ref[idx:]
+	}
+	target, err := svnScm.resolveTarget(ctx, root, name, auth)
+	if err != nil {
+		return errors.New("unable to checkout " + ref + " in svn working copy " + path + ": " + err.Error())
+	}
+	target = target + peg
+	svnScm.Logger.Debug("switching SVN working copy", UrlField(target), PathField(path))
+	_, err = svnScm.run(ctx, auth, "switch", "--ignore-ancestry", "--set-depth", "infinity", target, path)
+	if err != nil {
+		return errors.New("unable to checkout " + ref + " in svn working copy " + path + ": " + err.Error())
+	}
+	return nil
+}
+
+//switchedTo implements remoteSwitcher, the reference being compared with the
+// working copy without resolving it against the repository
+func (svnScm *SvnScmHandler) switchedTo(path string, ref string) bool {
+	ctx := context.Background()
+	out, err := svnScm.run(ctx, nil, "info", "--show-item", "repos-root-url", path)
+	if err != nil {
+		return false
+	}
+	reposRoot := strings.TrimSpace(out)
+	out, err = svnScm.run(ctx, nil, "info", "--show-item", "url", path)
+	if err != nil {
+		return false
+	}
+	current := strings.TrimSpace(out)
+	root := componentRoot(current, reposRoot)
+
+	name, peg := ref, ""
+	if idx := strings.LastIndex(ref, "@"); idx != -1 {
+		name, peg = ref[:idx], ref[idx+1:]
+	}
+	if peg != "" {
+		rev, err := svnScm.run(ctx, nil, "info", "--show-item", "revision", path)
+		if err != nil || strings.TrimSpace(rev) != peg {
+			return false
+		}
+	}
+	name = strings.Trim(name, "/")
+	var candidates []string
+	switch {
+	case name == "":
+		candidates = []string{root + "/" + svnTrunk, root}
+	case name == svnTrunk || strings.HasPrefix(name, svnTags+"/") || strings.HasPrefix(name, svnBranches+"/"):
+		candidates = []string{root + "/" + name}
+	default:
+		candidates = []string{root + "/" + svnTags + "/" + name, root + "/" + svnBranches + "/" + name}
+	}
+	for _, candidate := range candidates {
+		if current == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+//Revision implements "github.com/GroupePSA/componentizer.RevisionReader
+//
+//The revision is the path checked out, relative to the component root, pegged
+// to the revision of the working copy, as "trunk@42".
+func (svnScm *SvnScmHandler) Revision(path string) (string, error) {
+	ctx := context.Background()
+	out, err := svnScm.run(ctx, nil, "info", "--show-item", "repos-root-url", path)
+	if err != nil {
+		return "", errors.New("unable to access svn working copy " + path + ": " + err.Error())
+	}
+	reposRoot := strings.TrimSpace(out)
+	out, err = svnScm.run(ctx, nil, "info", "--show-item", "url", path)
+	if err != nil {
+		return "", errors.New("unable to access svn working copy " + path + ": " + err.Error())
+	}
+	current := strings.TrimSpace(out)
+	rev, err := svnScm.run(ctx, nil, "info", "--show-item", "revision", path)
+	if err != nil {
+		return "", errors.New("unable to access svn working copy " + path + ": " + err.Error())
+	}
+	rel := strings.TrimPrefix(strings.TrimPrefix(current, componentRoot(current, reposRoot)), "/")
+	return rel + "@" + strings.TrimSpace(rev), nil
+}
+
+//resolveTarget returns the url corresponding to the reference into the repository layout
+func (svnScm *SvnScmHandler) resolveTarget(ctx context.Context, root string, ref string, auth map[string]string) (string, error) {
+	ref = strings.Trim(ref, "/")
+	if ref == "" {
+		if svnScm.exists(ctx, root+"/"+svnTrunk, auth) {
+			return root + "/" + svnTrunk, nil
+		}
+		return root, nil
+	}
+	if ref == svnTrunk || strings.HasPrefix(ref, svnTags+"/") || strings.HasPrefix(ref, svnBranches+"/") {
+		// Layout paths are used as-is
+		return root + "/" + ref, nil
+	}
+	if tag := root + "/" + svnTags + "/" + ref; svnScm.exists(ctx, tag, auth) {
+		return tag, nil
+	}
+	svnScm.Logger.Debug("no tag found, checking out branch instead", RefField(ref))
+	if branch := root + "/" + svnBranches + "/" + ref; svnScm.exists(ctx, branch, auth) {
+		return branch, nil
+	}
+	return "", errors.New("no tag or branch named " + ref)
+}
+
+func (svnScm *SvnScmHandler) exists(ctx context.Context, u string, auth map[string]string) bool {
+	_, err := svnScm.run(ctx, auth, "info", "--show-item", "kind", u)
+	return err == nil
+}
+
+//run executes the svn client, which is killed if the context is done before its end
+func (svnScm *SvnScmHandler) run(ctx context.Context, auth map[string]string, args ...string) (string, error) {
+	args, stdin := svnArgs(auth, args...)
+	if stdin != nil {
+		if err := checkSvnPasswordFromStdin(ctx); err != nil {
+			return "", err
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "svn", args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+//svnArgs returns the arguments of the svn client along with its standard input, the
+// password being read from the standard input to keep it out of the process list
+func svnArgs(auth map[string]string, args ...string) ([]string, io.Reader) {
+	args = append([]string{"--non-interactive"}, args...)
+	var stdin io.Reader
+	if user, ok := auth["user"]; ok {
+		args = append(args, "--username", user, "--no-auth-cache")
+		if password, ok := auth["password"]; ok {
+			// Requires svn 1.10 or later
+			args = append(args, "--password-from-stdin")
+			stdin = strings.NewReader(password + "\n")
+		}
+	}
+	return args, stdin
+}
+
+//checkSvnPasswordFromStdin returns an error if the svn client found into the path is too old
+// to read the password from the standard input
+func checkSvnPasswordFromStdin(ctx context.Context) error {
+	path, err := exec.LookPath("svn")
+	if err != nil {
+		return err
+	}
+	svnVersionsMu.Lock()
+	v, ok := svnVersions[path]
+	svnVersionsMu.Unlock()
+	if !ok {
+		out, err := exec.CommandContext(ctx, path, "--version", "--quiet").Output()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return errors.New("unable to get the version of svn: " + err.Error())
+		}
+		fields := strings.Fields(string(out))
+		if len(fields) == 0 {
+			return errors.New("unable to get the version of svn")
+		}
+		v, err = parseVersion(fields[0])
+		if err != nil {
+			return errors.New("unable to get the version of svn: " + err.Error())
+		}
+		svnVersionsMu.Lock()
+		svnVersions[path] = v
+		svnVersionsMu.Unlock()
+	}
+	if v.compare(svnPasswordFromStdin) < 0 {
+		return errors.New("svn 1.10 or later is required to authenticate with a password, found svn " + v.String())
+	}
+	return nil
+}
+
+//componentRoot returns the root of the component layout from the url
+// currently checked out
+func componentRoot(current string, reposRoot string) string {
+	rel := strings.TrimPrefix(strings.TrimPrefix(current, reposRoot), "/")
+	parts := strings.Split(rel, "/")
+	for i, part := range parts {
+		switch part {
+		case svnTrunk:
+			return strings.TrimSuffix(reposRoot+"/"+strings.Join(parts[:i], "/"), "/")
+		case svnTags, svnBranches:
+			if i+1 < len(parts) {
+				return strings.TrimSuffix(reposRoot+"/"+strings.Join(parts[:i], "/"), "/")
+			}
+		}
+	}
+	return current
+}
+
+//svnURL returns the url understood by the svn client
+func svnURL(u *url.URL) string {
+	if u.Scheme == SchemeSvnFile {
+		c := *u
+		c.Scheme = SchemeFile
+		return c.String()
+	}
+	return u.String()
+}
@@ -0,0 +1,260 @@
+package componentizer
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSvnScmHandler(t *testing.T) {
+	if _, err := exec.LookPath("svnadmin"); err != nil {
+		t.Skip("svnadmin is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "componentizer_svn")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	repoPath := filepath.Join(dir, "repo")
+	root := "file://" + repoPath
+	srcPath := filepath.Join(dir, "src")
+	assert.Nil(t, os.MkdirAll(srcPath, 0777))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(srcPath, "ekara.yaml"), []byte("v1"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(srcPath, "new.yaml"), []byte("v2"), 0644))
+
+	runSvnTestCommand(t, "svnadmin", "create", repoPath)
+	runSvnTestCommand(t, "svn", "mkdir", "-m", "layout", root+"/trunk", root+"/tags", root+"/branches")
+	runSvnTestCommand(t, "svn", "import", "-m", "v1", filepath.Join(srcPath, "ekara.yaml"), root+"/trunk/ekara.yaml")
+	runSvnTestCommand(t, "svn", "copy", "-m", "tag", root+"/trunk", root+"/tags/1.0")
+	runSvnTestCommand(t, "svn", "import", "-m", "v2", filepath.Join(srcPath, "new.yaml"), root+"/trunk/new.yaml")
+
+	u, err := url.Parse("svn+file://" + repoPath)
+	if !assert.Nil(t, err) {
+		return
+	}
+	h := &SvnScmHandler{Logger: testLogger()}
+	wcPath := filepath.Join(dir, "wc")
+
+	assert.Nil(t, h.Fetch(context.Background(), u, wcPath, nil))
+	assert.True(t, h.Matches(u, wcPath))
+
+	assert.Nil(t, h.Switch(context.Background(), wcPath, ""))
+	assert.FileExists(t, filepath.Join(wcPath, "ekara.yaml"))
+	assert.FileExists(t, filepath.Join(wcPath, "new.yaml"))
+
+	assert.Nil(t, h.Switch(context.Background(), wcPath, "1.0"))
+	assert.FileExists(t, filepath.Join(wcPath, "ekara.yaml"))
+	_, err = os.Stat(filepath.Join(wcPath, "new.yaml"))
+	assert.True(t, os.IsNotExist(err))
+
+	rev, err := h.Revision(wcPath)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(rev, "tags/1.0@"), rev)
+	assert.Nil(t, h.Switch(context.Background(), wcPath, rev))
+
+	changed, err := h.Update(context.Background(), wcPath, nil)
+	assert.Nil(t, err)
+	assert.False(t, changed)
+	assert.True(t, h.Matches(u, wcPath))
+	assert.NotNil(t, h.Switch(context.Background(), wcPath, "missing"))
+}
+
+func runSvnTestCommand(t *testing.T, name string, args ...string) {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s failed: %s", name, string(out))
+	}
+}
+
+func TestSvnArgsKeepPasswordOffCommandLine(t *testing.T) {
+	args, stdin := svnArgs(map[string]string{"user": "john", "password": "secret"}, "info", "svn://host/repo")
+	assert.Equal(t, []string{"--non-interactive", "info", "svn://host/repo", "--username", "john", "--no-auth-cache", "--password-from-stdin"}, args)
+	if assert.NotNil(t, stdin) {
+		b, err := ioutil.ReadAll(stdin)
+		assert.Nil(t, err)
+		assert.Equal(t, "secret\n", string(b))
+	}
+
+	args, stdin = svnArgs(nil, "info")
+	assert.Equal(t, []string{"--non-interactive", "info"}, args)
+	assert.Nil(t, stdin)
+}
+
+// fakeSvnScript replaces the svn client, recording its calls and their standard
+// input; its version is read from the "version" file, the working copies are
+// described by the "info_<item>" files and the remote access fails unless the url
+// is listed into the "remote" file
+const fakeSvnScript = `#!/bin/sh
+dir=$(dirname "$0")
+if [ "$1" = "--version" ]; then
+	cat "$dir/version"
+	exit 0
+fi
+echo "$*" >> "$dir/calls"
+cat >> "$dir/stdin"
+[ "$1" = "--non-interactive" ] && shift
+if [ "$1" = "info" ]; then
+	case "$4" in
+	/*) cat "$dir/info_$3"; exit 0;;
+	esac
+	grep -qx "$4" "$dir/remote" 2>/dev/null && exit 0
+	echo "svn: E170013: Unable to connect to a repository at URL '$4'" >&2
+	exit 1
+fi
+exit 0
+`
+
+// useFakeSvn puts a fake svn client into the path and returns the directory holding
+// its description along with the function restoring the path
+func useFakeSvn(t *testing.T, info map[string]string) (string, func()) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake svn client requires a shell")
+	}
+	dir, err := ioutil.TempDir("", "componentizer_fake_svn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{"svn": fakeSvnScript, "version": "1.14.2 (r1899510)\n"}
+	for item, value := range info {
+		files["info_"+item] = value + "\n"
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	return dir, func() {
+		os.Setenv("PATH", path)
+		os.RemoveAll(dir)
+	}
+}
+
+// fakeSvnCalls returns the arguments of the calls of the fake svn client
+func fakeSvnCalls(t *testing.T, dir string) []string {
+	b, err := ioutil.ReadFile(filepath.Join(dir, "calls"))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(b)), "\n")
+}
+
+func TestSvnOfflineWithoutRemoteAccess(t *testing.T) {
+	fakeDir, restore := useFakeSvn(t, map[string]string{
+		"repos-root-url": "svn://host/repo",
+		"url":            "svn://host/repo/tags/1.0",
+		"revision":       "42",
+	})
+	defer restore()
+	dir, err := ioutil.TempDir("", "componentizer_svn_offline")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "comp"), 0755))
+
+	fetch := func(ref string, revision string) (fetchedComponent, error) {
+		repo, err := CreateRepository("svn://host/repo", ref, nil)
+		if err != nil {
+			return fetchedComponent{}, err
+		}
+		s := fetchSettings{offline: true, revision: revision}
+		h, err := getScmHandler(context.Background(), testLogger(), dir, testComponent{id: "comp", repo: repo}, s)
+		if err != nil {
+			return fetchedComponent{}, err
+		}
+		return h(context.Background())
+	}
+
+	// The working copy is already on the reference
+	fComp, err := fetch("1.0", "")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "tags/1.0@42", fComp.revision)
+	}
+	_, err = fetch("1.0", "tags/1.0@42")
+	assert.Nil(t, err)
+
+	// Switching would require the repository
+	_, err = fetch("2.0", "")
+	assert.True(t, errors.Is(err, errNotAvailableOffline))
+	_, err = fetch("1.0", "tags/1.0@41")
+	assert.True(t, errors.Is(err, errNotAvailableOffline))
+
+	for _, call := range fakeSvnCalls(t, fakeDir) {
+		assert.False(t, strings.Contains(call, "svn://"), call)
+		assert.False(t, strings.Contains(call, "switch"), call)
+	}
+}
+
+func TestSvnSwitchWithFetchAuthentication(t *testing.T) {
+	fakeDir, restore := useFakeSvn(t, map[string]string{
+		"repos-root-url": "svn://host/repo",
+		"url":            "svn://host/repo",
+		"revision":       "42",
+	})
+	defer restore()
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(fakeDir, "remote"), []byte("svn://host/repo/tags/1.0\n"), 0644))
+	dir, err := ioutil.TempDir("", "componentizer_svn_auth")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	repo, err := CreateRepository("svn://host/repo", "1.0", map[string]string{"method": "basic", "user": "john", "password": "secret"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	h, err := getScmHandler(context.Background(), testLogger(), dir, testComponent{id: "comp", repo: repo}, fetchSettings{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, err = h(context.Background())
+	assert.Nil(t, err)
+
+	// Every access to the repository is authenticated
+	remote := 0
+	for _, call := range fakeSvnCalls(t, fakeDir) {
+		if strings.Contains(call, "svn://") {
+			remote++
+			assert.True(t, strings.HasSuffix(call, "--username john --no-auth-cache --password-from-stdin"), call)
+		}
+	}
+	assert.Equal(t, 3, remote)
+	b, err := ioutil.ReadFile(filepath.Join(fakeDir, "stdin"))
+	assert.Nil(t, err)
+	assert.Equal(t, strings.Repeat("secret\n", remote), string(b))
+}
+
+func TestSvnPasswordRequiresRecentClient(t *testing.T) {
+	fakeDir, restore := useFakeSvn(t, nil)
+	defer restore()
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(fakeDir, "version"), []byte("1.9.7 (r1800392)\n"), 0644))
+	dir, err := ioutil.TempDir("", "componentizer_svn_version")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	u, _ := url.Parse("svn://host/repo")
+	h := &SvnScmHandler{Logger: testLogger()}
+	err = h.Fetch(context.Background(), u, filepath.Join(dir, "wc"), map[string]string{"user": "john", "password": "secret"})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "svn 1.10 or later is required")
+	}
+	assert.Equal(t, []string{""}, fakeSvnCalls(t, fakeDir))
+
+	// Older clients remain usable without password
+	assert.Nil(t, h.Fetch(context.Background(), u, filepath.Join(dir, "wc"), map[string]string{"user": "john"}))
+}
@@ -1,6 +1,7 @@
 package componentizer
 
 import (
+	"context"
 	"io/ioutil"
 	"math/rand"
 	"os"
@@ -13,8 +14,9 @@ import (
 	"github.com/oklog/ulid"
 )
 
-// runTemplate runs the templates defined into a path
-func executeTemplate(path string, patterns []string, ctx TemplateContext) (string, error) {
+// runTemplate runs the templates defined into a path, the templated copy being
+// removed if the context is done before the end
+func executeTemplate(ctx context.Context, path string, patterns []string, tplC TemplateContext) (string, error) {
 	if len(patterns) > 0 {
 		globs := make([]gl.Glob, 0, 0)
 		files := make([]string, 0, 0)
@@ -27,6 +29,9 @@ func executeTemplate(path string, patterns []string, ctx TemplateContext) (strin
 			globs = append(globs, gl.MustCompile(pa, '/'))
 		}
 		err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+			if err := canceled(ctx, "templating"); err != nil {
+				return err
+			}
 			for _, p := range patterns {
 				pa := filepath.Join(path, p)
 				if path == pa {
@@ -63,12 +68,17 @@ func executeTemplate(path string, patterns []string, ctx TemplateContext) (strin
 
 		uuid = genUlid()
 		tmpPath = path + "_" + uuid
-		err = copyDir(path, tmpPath)
+		err = copyDir(ctx, path, tmpPath)
 		if err != nil {
+			os.RemoveAll(tmpPath)
 			return "", err
 		}
 
 		for _, input := range files {
+			if err := canceled(ctx, "templating"); err != nil {
+				os.RemoveAll(tmpPath)
+				return "", err
+			}
 			input = strings.Replace(input, path, tmpPath, -1)
 
 			content, err := ioutil.ReadFile(input)
@@ -76,7 +86,7 @@ func executeTemplate(path string, patterns []string, ctx TemplateContext) (strin
 				return "", err
 			}
 
-			templatedContent, err := ctx.Execute(string(content))
+			templatedContent, err := tplC.Execute(string(content))
 			if err != nil {
 				return "", err
 			}
@@ -1,6 +1,7 @@
 package componentizer
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -83,10 +84,19 @@ func copyFile(src, dst string) (err error) {
 	return
 }
 
+//canceled returns an error wrapping the context error if the context is done
+func canceled(ctx context.Context, action string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s canceled: %w", action, err)
+	}
+	return nil
+}
+
 // CopyDir recursively copies a directory tree, attempting to preserve permissions.
 // Source directory must exist, destination directory must *not* exist.
 // Symlinks are ignored and skipped.
-func copyDir(src string, dst string) (err error) {
+// The copy stops as soon as the context is done.
+func copyDir(ctx context.Context, src string, dst string) (err error) {
 	src = filepath.Clean(src)
 	dst = filepath.Clean(dst)
 
@@ -117,11 +127,15 @@ func copyDir(src string, dst string) (err error) {
 	}
 
 	for _, entry := range entries {
+		err = canceled(ctx, "copy of "+src)
+		if err != nil {
+			return
+		}
 		srcPath := filepath.Join(src, entry.Name())
 		dstPath := filepath.Join(dst, entry.Name())
 
 		if entry.IsDir() {
-			err = copyDir(srcPath, dstPath)
+			err = copyDir(ctx, srcPath, dstPath)
 			if err != nil {
 				return
 			}
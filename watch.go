@@ -0,0 +1,191 @@
+package componentizer
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4"
+)
+
+type (
+	//WatchEvent is the outcome of the refresh of the components changed into their
+	// source directories
+	WatchEvent struct {
+		// Components holds the identifiers of the refreshed components, in the parsing order
+		Components []string
+		// Model is the final model merged again from the refreshed components
+		Model Model
+		// Changed is true if the final model changed
+		Changed bool
+		// Usable holds the refreshed components ready to be used, templated again
+		// with the template context of the initialization; the receiver must
+		// release them
+		Usable []UsableComponent
+		// Err is the first error which made a refresh fail, if any
+		Err error
+	}
+
+	//watcher notifies the changes made into watched paths
+	watcher interface {
+		// add watches the path, recursively if it's a directory
+		add(path string) error
+		// changes returns the channel receiving the changed paths, closed once the
+		// watcher is closed
+		changes() <-chan string
+		// close stops watching the paths
+		close() error
+	}
+)
+
+//defaultPollInterval is the interval between two inspections of the watched paths
+// when they are polled
+const defaultPollInterval = time.Second
+
+func (cm *componentManager) Watch(ctx context.Context, debounce time.Duration) (<-chan WatchEvent, error) {
+	// Watch the source directories of the local components
+	roots := map[string][]string{}
+	for _, fComp := range cm.fetchedComponents() {
+		if fComp.location != nil && fComp.location.Scheme == SchemeFile {
+			root := filepath.Clean(fComp.location.Path)
+			roots[root] = append(roots[root], fComp.id)
+		}
+	}
+	if len(roots) == 0 {
+		return nil, errors.New("no component located with the file scheme to watch")
+	}
+	w, err := newWatcher(cm.l)
+	if err != nil {
+		return nil, err
+	}
+	for root := range roots {
+		err = w.add(root)
+		if err != nil {
+			w.close()
+			return nil, err
+		}
+		cm.l.Debug("watching component source", PathField(root))
+	}
+
+	res := make(chan WatchEvent)
+	go func() {
+		defer close(res)
+		defer w.close()
+		pending := map[string]bool{}
+		var fire <-chan time.Time
+		var timer *time.Timer
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case p, ok := <-w.changes():
+				if !ok {
+					return
+				}
+				for root, ids := range roots {
+					if !isWatchedChange(root, p) {
+						continue
+					}
+					for _, id := range ids {
+						pending[id] = true
+					}
+				}
+				if len(pending) == 0 {
+					continue
+				}
+				// Wait for the changes to settle down
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.NewTimer(debounce)
+				fire = timer.C
+			case <-fire:
+				fire = nil
+				e := cm.refreshAll(ctx, pending)
+				pending = map[string]bool{}
+				select {
+				case res <- e:
+				case <-ctx.Done():
+					for _, u := range e.Usable {
+						u.Release()
+					}
+					return
+				}
+			}
+		}
+	}()
+	return res, nil
+}
+
+//isWatchedChange returns true if the path changed into the watched root is part of
+// its content, the git metadata changing on their own
+func isWatchedChange(root string, p string) bool {
+	if p != root && !strings.HasPrefix(p, root+string(filepath.Separator)) {
+		return false
+	}
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return false
+	}
+	return rel != git.GitDirName && !strings.HasPrefix(rel, git.GitDirName+string(filepath.Separator))
+}
+
+//refreshAll refreshes the components in the parsing order, the components which have
+// not been parsed being refreshed last, and templates them again
+func (cm *componentManager) refreshAll(ctx context.Context, ids map[string]bool) WatchEvent {
+	e := WatchEvent{}
+	for _, id := range cm.ComponentOrder() {
+		if ids[id] {
+			e.Components = append(e.Components, id)
+			delete(ids, id)
+		}
+	}
+	var others []string
+	for id := range ids {
+		others = append(others, id)
+	}
+	sort.Strings(others)
+	e.Components = append(e.Components, others...)
+
+	var refreshed []string
+	for _, id := range e.Components {
+		// The working trees are copied to pick up the changes not committed yet
+		changed, err := cm.refresh(ctx, id, true)
+		if err != nil {
+			cm.l.Warn("unable to refresh the component", ComponentField(id), ErrorField(err))
+			if e.Err == nil {
+				e.Err = err
+			}
+			continue
+		}
+		e.Changed = e.Changed || changed
+		refreshed = append(refreshed, id)
+	}
+	e.Model = cm.Model()
+
+	var tplC TemplateContext
+	cm.mu.RLock()
+	if cm.discovery != nil {
+		tplC = cm.discovery.tplC
+	}
+	cm.mu.RUnlock()
+	for _, id := range refreshed {
+		fComp, ok := cm.isComponentFetched(id)
+		if !ok {
+			continue
+		}
+		u, err := cm.UseContext(ctx, fComp.component, tplC)
+		if err != nil {
+			cm.l.Warn("unable to template the component", ComponentField(id), ErrorField(err))
+			if e.Err == nil {
+				e.Err = err
+			}
+			continue
+		}
+		e.Usable = append(e.Usable, u)
+	}
+	return e
+}
@@ -0,0 +1,133 @@
+//go:build linux
+// +build linux
+
+package componentizer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+//inotifyMask selects the inotify events reporting a change of content
+const inotifyMask = syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_CLOSE_WRITE | syscall.IN_CREATE |
+	syscall.IN_DELETE | syscall.IN_DELETE_SELF | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_MOVE_SELF
+
+//inotifyWatcher detects the changes through the inotify notifications of Linux
+type inotifyWatcher struct {
+	l  Logger
+	fd int
+	f  *os.File
+	// mu guards the watched paths
+	mu sync.Mutex
+	// paths holds the watched paths by watch descriptor
+	paths map[int]string
+	c     chan string
+	done  chan struct{}
+	once  sync.Once
+}
+
+//newWatcher returns a watcher based on inotify, or polling the watched paths if
+// inotify is not available
+func newWatcher(l Logger) (watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		l.Warn("inotify not available, polling the watched paths", ErrorField(err))
+		return newPollWatcher(defaultPollInterval), nil
+	}
+	w := &inotifyWatcher{
+		l:  l,
+		fd: fd,
+		// The descriptor being non-blocking, closing the file interrupts the pending read
+		f:     os.NewFile(uintptr(fd), "inotify"),
+		paths: map[int]string{},
+		c:     make(chan string),
+		done:  make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *inotifyWatcher) add(path string) error {
+	return filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Directories are watched one by one, inotify not being recursive
+		if !info.IsDir() && p != path {
+			return nil
+		}
+		wd, err := syscall.InotifyAddWatch(w.fd, p, inotifyMask)
+		if err != nil {
+			return os.NewSyscallError("inotify_add_watch", err)
+		}
+		w.mu.Lock()
+		w.paths[wd] = p
+		w.mu.Unlock()
+		return nil
+	})
+}
+
+func (w *inotifyWatcher) changes() <-chan string {
+	return w.c
+}
+
+func (w *inotifyWatcher) close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		err = w.f.Close()
+	})
+	return err
+}
+
+func (w *inotifyWatcher) run() {
+	defer close(w.c)
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.f.Read(buf)
+		if err != nil {
+			select {
+			case <-w.done:
+			default:
+				w.l.Error("unable to read the inotify events", ErrorField(err))
+			}
+			return
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			offset = nameStart + int(raw.Len)
+			name := strings.TrimRight(string(buf[nameStart:offset]), "\x00")
+
+			w.mu.Lock()
+			dir, ok := w.paths[int(raw.Wd)]
+			if raw.Mask&syscall.IN_IGNORED != 0 {
+				delete(w.paths, int(raw.Wd))
+			}
+			w.mu.Unlock()
+			if !ok || raw.Mask&syscall.IN_IGNORED != 0 {
+				continue
+			}
+
+			p := dir
+			if name != "" {
+				p = filepath.Join(dir, name)
+			}
+			if raw.Mask&syscall.IN_CREATE != 0 && raw.Mask&syscall.IN_ISDIR != 0 {
+				// Watch the created directory too
+				if err := w.add(p); err != nil {
+					w.l.Warn("unable to watch the created directory", PathField(p), ErrorField(err))
+				}
+			}
+			select {
+			case w.c <- p:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package componentizer
+
+//newWatcher returns a watcher polling the watched paths, file system notifications
+// being only supported on Linux
+func newWatcher(l Logger) (watcher, error) {
+	return newPollWatcher(defaultPollInterval), nil
+}
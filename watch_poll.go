@@ -0,0 +1,122 @@
+package componentizer
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type (
+	//pollWatcher detects the changes by inspecting periodically the watched paths
+	pollWatcher struct {
+		interval time.Duration
+		mu       sync.Mutex
+		// snapshots holds the state of the files located under each watched path
+		snapshots map[string]map[string]fileState
+		c         chan string
+		done      chan struct{}
+		once      sync.Once
+	}
+
+	//fileState is what is compared to detect the change of a file
+	fileState struct {
+		modTime time.Time
+		size    int64
+		mode    os.FileMode
+	}
+)
+
+func newPollWatcher(interval time.Duration) *pollWatcher {
+	w := &pollWatcher{
+		interval:  interval,
+		snapshots: map[string]map[string]fileState{},
+		c:         make(chan string),
+		done:      make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *pollWatcher) add(path string) error {
+	s, err := snapshot(path)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.snapshots[path] = s
+	return nil
+}
+
+func (w *pollWatcher) changes() <-chan string {
+	return w.c
+}
+
+func (w *pollWatcher) close() error {
+	w.once.Do(func() {
+		close(w.done)
+	})
+	return nil
+}
+
+func (w *pollWatcher) run() {
+	defer close(w.c)
+	t := time.NewTicker(w.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-t.C:
+		}
+		for _, path := range w.changed() {
+			select {
+			case w.c <- path:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+//changed returns the watched paths whose content changed since the last inspection
+func (w *pollWatcher) changed() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var res []string
+	for path, previous := range w.snapshots {
+		// A removed path is seen as empty
+		current, _ := snapshot(path)
+		if !sameSnapshot(previous, current) {
+			w.snapshots[path] = current
+			res = append(res, path)
+		}
+	}
+	return res
+}
+
+//snapshot returns the state of the files located under the path
+func snapshot(path string) (map[string]fileState, error) {
+	res := map[string]fileState{}
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		res[p] = fileState{modTime: info.ModTime(), size: info.Size(), mode: info.Mode()}
+		return nil
+	})
+	return res, err
+}
+
+func sameSnapshot(a map[string]fileState, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for p, s := range a {
+		if o, ok := b[p]; !ok || !o.modTime.Equal(s.modTime) || o.size != s.size || o.mode != s.mode {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package componentizer
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatch(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	src := filepath.Join(tester.fixDir, "main")
+	assert.Nil(t, os.MkdirAll(filepath.Join(src, "sub"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(src, "ekara.yaml"), []byte("value: v1"), 0644))
+	repo, err := CreateRepository("file://"+src, "", nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+	mainComp := testComponent{id: "main", repo: repo}
+	cm := tester.ComponentManager()
+	if !assert.Nil(t, tester.Init(mainComp)) {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := cm.Watch(ctx, 50*time.Millisecond)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// Changes into the source directory are picked up
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(src, "sub", "other.txt"), []byte("other"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(src, "ekara.yaml"), []byte("value: v2"), 0644))
+	select {
+	case e := <-events:
+		if assert.Nil(t, e.Err) {
+			assert.Equal(t, []string{"main"}, e.Components)
+			assert.True(t, e.Changed)
+			assert.Equal(t, "v2", e.Model.(testModel).values["main"])
+			if assert.Len(t, e.Usable, 1) {
+				e.Usable[0].Release()
+			}
+		}
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "no event received")
+	}
+	assertTestFileContent(t, filepath.Join(tester.compDir, "main", "sub", "other.txt"), "other")
+
+	// The channel is closed once the context is done
+	cancel()
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok)
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "channel not closed")
+	}
+}
+
+func TestWatchGitWorkingTree(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	main := tester.CreateDir("main")
+	main.WriteCommit("ekara.yaml", "value: v1")
+	mainComp := templatedTestComponent{testComponent{id: "main", repo: main.AsRepository("")}}
+	cm := tester.ComponentManager()
+	if !assert.Nil(t, tester.Init(mainComp)) {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := cm.Watch(ctx, 50*time.Millisecond)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// The changes of the git directory alone are ignored
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(main.path, ".git", "index.lock"), nil, 0644))
+	select {
+	case e := <-events:
+		assert.Fail(t, "unexpected event", "%v", e.Components)
+	case <-time.After(500 * time.Millisecond):
+	}
+	assert.Nil(t, os.Remove(filepath.Join(main.path, ".git", "index.lock")))
+
+	// The uncommitted changes are picked up and templated again
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(main.path, "ekara.yaml"), []byte("value: v2"), 0644))
+	select {
+	case e := <-events:
+		if assert.Nil(t, e.Err) {
+			assert.Equal(t, "v2", e.Model.(testModel).values["main"])
+			if assert.Len(t, e.Usable, 1) {
+				assert.True(t, e.Usable[0].Templated())
+				assertTestFileContent(t, filepath.Join(e.Usable[0].RootPath(), "ekara.yaml"), "value: v2")
+				e.Usable[0].Release()
+				assert.False(t, DirExist(e.Usable[0].RootPath()))
+			}
+		}
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "no event received")
+	}
+	assert.False(t, DirExist(filepath.Join(tester.compDir, "main", ".git")))
+}
+
+func TestWatchWithoutLocalComponents(t *testing.T) {
+	tester := createTestComponentTester(t)
+	defer tester.Clean()
+
+	// Nothing has been fetched
+	_, err := tester.ComponentManager().Watch(context.Background(), time.Millisecond)
+	assert.NotNil(t, err)
+}
+
+func TestPollWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "componentizer_watch")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	w := newPollWatcher(10 * time.Millisecond)
+	if !assert.Nil(t, w.add(dir)) {
+		return
+	}
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "file.txt"), []byte("content"), 0644))
+	select {
+	case p := <-w.changes():
+		assert.Equal(t, dir, p)
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "no change detected")
+	}
+	w.close()
+	for range w.changes() {
+	}
+}